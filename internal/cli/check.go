@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/spf13/cobra"
+)
+
+// driftError returned when the drift exceeds the thresholds, so that Execute can
+// exit with exitDrift rather than exitError.
+type driftError struct {
+	violations []string
+}
+
+func (d *driftError) Error() string {
+	return fmt.Sprintf("drift exceeds thresholds: %s", strings.Join(d.violations, "; "))
+}
+
+// checkResult the structured result of the check command
+type checkResult struct {
+	Total            int            `json:"total"`
+	Unmanaged        int            `json:"unmanaged"`
+	UnmanagedPercent float64        `json:"unmanagedPercent"`
+	UnmanagedByType  map[string]int `json:"unmanagedByType"`
+	Violations       []string       `json:"violations"`
+	Passed           bool           `json:"passed"`
+}
+
+func check() *cobra.Command {
+	var (
+		maxUnmanaged        int
+		maxUnmanagedPercent float64
+		maxUnmanagedByType  map[string]int
+		format              string
+	)
+	var formatOptions = []string{
+		formatText,
+		formatJSON,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "check that the resources not managed by IaC are within thresholds, for CI gating",
+		Long: `Check that the resources in AWS Config that are not managed by IaC, directly or via
+		their owner, and that are not accepted by an ignore file, are within the given thresholds.
+		If no threshold is given, any unmanaged resource fails the check.
+
+		Exit codes are 0 when within the thresholds, 1 on any error, 2 when the thresholds are exceeded.`,
+		Example: `
+		aws-config check --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate> --max-unmanaged 10
+		aws-config check --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate> --max-unmanaged-percent 2.5
+		aws-config check --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate> --max-unmanaged-type AWS::EC2::SecurityGroup=0,AWS::IAM::Role=5
+		`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch format {
+			case formatText, formatJSON:
+			default:
+				return fmt.Errorf("invalid format: %s", format)
+			}
+			summary, err := compare.Summarize(items)
+			if err != nil {
+				return fmt.Errorf("unable to summarize: %w", err)
+			}
+			config, _ := summary.Source(compare.SourceConfig)
+			result := checkResult{
+				Total:           config.Total,
+				Unmanaged:       config.OnlyCount,
+				UnmanagedByType: make(map[string]int),
+			}
+			if config.Total > 0 {
+				result.UnmanagedPercent = float64(config.OnlyCount) * 100 / float64(config.Total)
+			}
+			for _, only := range config.Only {
+				if count := only.Mapped + only.Unmapped; count > 0 {
+					result.UnmanagedByType[only.ResourceType] = count
+				}
+			}
+
+			if maxUnmanaged < 0 && maxUnmanagedPercent < 0 && len(maxUnmanagedByType) == 0 {
+				maxUnmanaged = 0
+			}
+			if maxUnmanaged >= 0 && result.Unmanaged > maxUnmanaged {
+				result.Violations = append(result.Violations, fmt.Sprintf("unmanaged resources %d exceeds maximum %d", result.Unmanaged, maxUnmanaged))
+			}
+			if maxUnmanagedPercent >= 0 && result.UnmanagedPercent > maxUnmanagedPercent {
+				result.Violations = append(result.Violations, fmt.Sprintf("unmanaged resources %.2f%% exceeds maximum %.2f%%", result.UnmanagedPercent, maxUnmanagedPercent))
+			}
+			var types []string
+			for resourceType := range maxUnmanagedByType {
+				types = append(types, resourceType)
+			}
+			sort.Strings(types)
+			for _, resourceType := range types {
+				if count := result.UnmanagedByType[resourceType]; count > maxUnmanagedByType[resourceType] {
+					result.Violations = append(result.Violations, fmt.Sprintf("unmanaged %s %d exceeds maximum %d", resourceType, count, maxUnmanagedByType[resourceType]))
+				}
+			}
+			result.Passed = len(result.Violations) == 0
+
+			if format == formatJSON {
+				if err := writeJSON(cmd.OutOrStdout(), kindCheck, result); err != nil {
+					return err
+				}
+			} else {
+				out := cmd.OutOrStdout()
+				fmt.Fprintf(out, "Unmanaged: %d of %d (%.2f%%)\n", result.Unmanaged, result.Total, result.UnmanagedPercent)
+				for _, violation := range result.Violations {
+					fmt.Fprintf(out, "FAIL: %s\n", violation)
+				}
+				if result.Passed {
+					fmt.Fprintf(out, "PASS\n")
+				}
+			}
+			if !result.Passed {
+				// the report already explains it, so do not print usage
+				cmd.SilenceUsage = true
+				return &driftError{violations: result.Violations}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&maxUnmanaged, "max-unmanaged", -1, "maximum number of unmanaged resources; negative for no limit")
+	cmd.Flags().Float64Var(&maxUnmanagedPercent, "max-unmanaged-percent", -1, "maximum percentage of resources in AWS Config that are unmanaged; negative for no limit")
+	cmd.Flags().StringToIntVar(&maxUnmanagedByType, "max-unmanaged-type", nil, "maximum number of unmanaged resources of a type, as <type>=<count>; may be repeated or comma-separated")
+	cmd.Flags().StringVar(&format, "format", formatText, "format for printing output, options are: "+strings.Join(formatOptions, " "))
+	return cmd
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func codegen() *cobra.Command {
+	var withImports bool
+	cmd := &cobra.Command{
+		Use:   "codegen",
+		Short: "generate terraform resource blocks for resources only in AWS Config",
+		Long: `Generate terraform resource blocks for the resources that are only in AWS Config, from their
+		configuration as recorded by AWS Config, with the same names as the import subcommand gives them.
+		Only some resource types are supported: ` + strings.Join(compare.CodegenTypes(), ", ") + `.
+		Each block is a starting point, which should be checked with terraform plan after importing.
+		Can be restricted to just one or a few resource types.`,
+		Example: `
+		aws-config codegen --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive --import > unmanaged.tf
+		aws-config codegen --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive AWS::EC2::SecurityGroup
+		`,
+		Annotations: map[string]string{annotationRawConfiguration: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource := make(map[string]bool)
+			for _, arg := range args {
+				resource[arg] = true
+			}
+			hasRestrictions := len(resource) > 0
+			out := cmd.OutOrStdout()
+			var count int
+			for _, imp := range compare.TerraformImports(items) {
+				if hasRestrictions && !resource[imp.Item.ResourceType] {
+					continue
+				}
+				block, ok, err := imp.ResourceBlock()
+				if err != nil {
+					return err
+				}
+				if !ok {
+					log.Debugf("no resource block can be generated for %s", imp.Address())
+					continue
+				}
+				if count > 0 {
+					fmt.Fprintln(out)
+				}
+				count++
+				if withImports {
+					fmt.Fprint(out, imp.ImportBlock())
+					fmt.Fprintln(out)
+				}
+				fmt.Fprint(out, block)
+			}
+
+			// no error
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&withImports, "import", false, "precede each resource block with its import block")
+	return cmd
+}
@@ -4,18 +4,24 @@ import (
 	"encoding/csv"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/iac-reconciler/aws-config/pkg/load"
 	"github.com/spf13/cobra"
 )
 
 func detail() *cobra.Command {
 	var (
-		descending     bool
-		sortBy, format string
-		top            int
+		descending, showTerraform, showOwner bool
+		unmanaged                            bool
+		sortBy, format                       string
+		maxAge, minAge                       string
+		top                                  int
+		attributes                           []string
 	)
 
 	const (
@@ -44,32 +50,73 @@ func detail() *cobra.Command {
 		formatTabSep,
 		formatCSV,
 		formatTable,
+		formatJSON,
+		formatNDJSON,
 	}
 
 	cmd := &cobra.Command{
 		Use:   "detail",
 		Short: "show details for specific resources in the sources",
 		Long: `Show detail for specific resources in the sources. By default, shows all resources.
-		Can be restricted to just one or a few resource types.`,
+		Can be restricted to just one or a few resource types, to resources that are not managed
+		by IaC, and to resources created within or before an age, by their creation time in AWS Config.`,
 		Example: `
 		aws-config detail --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate>
 		aws-config detail --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate> AWS::EC2::Volume
 		aws-config detail --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate> AWS::EC2::Volume AWS::EC2::RouteTable
+		aws-config detail --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate> --unmanaged --max-age 7d
+		aws-config detail --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate> --attribute configuration.volumeType --attribute 'tags["team"]' AWS::EC2::Volume
 		`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			var paths []load.Path
+			for _, attribute := range attributes {
+				path, err := load.ParsePath(attribute)
+				if err != nil {
+					return err
+				}
+				paths = append(paths, path)
+			}
 			resource := make(map[string]bool)
 			for _, arg := range args {
 				resource[arg] = true
 			}
 			hasRestrictions := len(resource) > 0
+			var createdAfter, createdBefore time.Time
+			now := time.Now()
+			if maxAge != "" {
+				age, err := parseAge(maxAge)
+				if err != nil {
+					return fmt.Errorf("invalid max-age: %w", err)
+				}
+				createdAfter = now.Add(-age)
+			}
+			if minAge != "" {
+				age, err := parseAge(minAge)
+				if err != nil {
+					return fmt.Errorf("invalid min-age: %w", err)
+				}
+				createdBefore = now.Add(-age)
+			}
 			var results []*compare.LocatedItem
 			for _, item := range items {
 				if item.Ephemeral() {
 					continue
 				}
-				if !hasRestrictions || resource[item.ResourceType] {
-					results = append(results, item)
+				if hasRestrictions && !resource[item.ResourceType] {
+					continue
 				}
+				if unmanaged && (!item.Source(compare.SourceConfig) || item.Owned() || item.Accepted()) {
+					continue
+				}
+				// resources whose creation time is unknown have no age
+				created := item.CreationTime
+				if !createdAfter.IsZero() && (created.IsZero() || created.Before(createdAfter)) {
+					continue
+				}
+				if !createdBefore.IsZero() && (created.IsZero() || created.After(createdBefore)) {
+					continue
+				}
+				results = append(results, item)
 			}
 			// print the detail
 			sort.Slice(results, func(i, j int) bool {
@@ -109,6 +156,30 @@ func detail() *cobra.Command {
 			case top < 0:
 				results = results[len(results)+top:]
 			}
+			switch format {
+			case formatJSON:
+				records := make([]itemRecord, 0, len(results))
+				for _, item := range results {
+					record, err := newDetailRecord(item, paths)
+					if err != nil {
+						return err
+					}
+					records = append(records, record)
+				}
+				return writeJSON(cmd.OutOrStdout(), kindItem, records)
+			case formatNDJSON:
+				w := newNDJSONWriter(cmd.OutOrStdout())
+				for _, item := range results {
+					record, err := newDetailRecord(item, paths)
+					if err != nil {
+						return err
+					}
+					if err := w.Write(kindItem, record); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
 			var printer *csv.Writer
 			switch format {
 			case formatSpaceSep:
@@ -129,8 +200,17 @@ func detail() *cobra.Command {
 				return fmt.Errorf("invalid format: %s", format)
 			}
 			defer printer.Flush()
-			headerRow := []string{"ResourceType", "ResourceName", "ResourceID", "ARN", "owned"}
-			headerRow = append(headerRow, compare.SourceKeys...)
+			headerRow := []string{"ResourceType", "ResourceName", "ResourceID", "ARN", "owned", "accepted"}
+			headerRow = append(headerRow, sourceKeys...)
+			if showTerraform {
+				headerRow = append(headerRow, "terraform-resources")
+			}
+			if showOwner {
+				headerRow = append(headerRow, "owner-reason", "owner-chain")
+			}
+			for _, path := range paths {
+				headerRow = append(headerRow, path.String())
+			}
 			printer.Write(headerRow)
 			for _, item := range results {
 				var row []string
@@ -140,6 +220,7 @@ func detail() *cobra.Command {
 					item.ResourceID,
 					item.ARN,
 					fmt.Sprintf("%v", item.Owned()),
+					fmt.Sprintf("%v", item.Accepted()),
 				}
 				for _, key := range entries {
 					if key == "" {
@@ -147,9 +228,37 @@ func detail() *cobra.Command {
 					}
 					row = append(row, key)
 				}
-				for _, key := range compare.SourceKeys {
+				for _, key := range sourceKeys {
 					row = append(row, fmt.Sprintf("%v", item.Source(key)))
 				}
+				if showTerraform {
+					var addresses []string
+					for _, resource := range item.TerraformResources() {
+						addresses = append(addresses, resource.String())
+					}
+					if len(addresses) == 0 {
+						addresses = []string{"-"}
+					}
+					row = append(row, strings.Join(addresses, ";"))
+				}
+				if showOwner {
+					for _, key := range []string{item.OwnershipReason(), ownerChain(item)} {
+						if key == "" {
+							key = "-"
+						}
+						row = append(row, key)
+					}
+				}
+				for _, path := range paths {
+					values, err := item.LookupString(path)
+					if err != nil {
+						return err
+					}
+					if len(values) == 0 {
+						values = []string{"-"}
+					}
+					row = append(row, strings.Join(values, ";"))
+				}
 				printer.Write(row)
 			}
 
@@ -161,6 +270,41 @@ func detail() *cobra.Command {
 	cmd.Flags().BoolVar(&descending, "descending", false, "sort by descending instead of ascending; for by-type and detail")
 	cmd.Flags().StringVar(&sortBy, "sort", sortByDefault, "sort order for results, options are: "+strings.Join(sortOptions, " ")+", as well as 'count-<field>', where <field> is any supported field, e.g. terraform or eks; for by-type and detail")
 	cmd.Flags().IntVar(&top, "top", 0, "limit to the top x results, use 0 for all, negative for last; for by-type and detail")
+	cmd.Flags().BoolVar(&showTerraform, "show-terraform", false, "add a column listing the statefile and address of each terraform resource that matched, in the form <statefile>:<address>, separated by ';'")
+	cmd.Flags().BoolVar(&showOwner, "show-owner", false, "add columns with the reason the resource is owned by its parent, and the full chain of owners up to the root owner")
+	cmd.Flags().BoolVar(&unmanaged, "unmanaged", false, "only resources in AWS Config that are not managed by IaC, directly or via their parent, and not accepted")
+	cmd.Flags().StringVar(&maxAge, "max-age", "", "only resources created at most this long ago, by their resourceCreationTime in AWS Config, e.g. 12h, 7d or 2w")
+	cmd.Flags().StringVar(&minAge, "min-age", "", "only resources created at least this long ago, by their resourceCreationTime in AWS Config, e.g. 12h, 7d or 2w")
+	cmd.Flags().StringArrayVar(&attributes, flagAttribute, nil, "add a column with the values of the attribute at the path in the item as recorded by AWS Config, e.g. configuration.instanceType or supplementaryConfiguration.BucketVersioningConfiguration.status, separated by ';'; in json, an attributes object keyed by the path; may be repeated")
 	cmd.Flags().StringVar(&format, "format", formatSpaceSep, "format for printing output, options are: "+strings.Join(formatOptions, " "))
 	return cmd
 }
+
+// newDetailRecord the record of the item, with the values of each of the attribute paths
+func newDetailRecord(item *compare.LocatedItem, paths []load.Path) (itemRecord, error) {
+	record := newItemRecord(item)
+	for _, path := range paths {
+		values, err := item.Lookup(path)
+		if err != nil {
+			return record, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if record.Attributes == nil {
+			record.Attributes = make(map[string][]interface{})
+		}
+		record.Attributes[path.String()] = values
+	}
+	return record, nil
+}
+
+// parseAge parse a duration, which may also be a whole number of days or weeks, e.g. 7d or 2w
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); strings.HasSuffix(s, suffix) && err == nil {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func drift() *cobra.Command {
+	var format string
+	var formatOptions = []string{
+		formatText,
+		formatJSON,
+		formatNDJSON,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "list attributes that differ between AWS Config and terraform",
+		Long: `List the attributes of resources in both AWS Config and terraform whose values differ,
+		i.e. that were changed outside of terraform since it last applied or refreshed. Tags and the
+		rules of security groups are compared for every resource, along with key attributes of some
+		resource types, e.g. the instance type of EC2 instances, or the encryption of EBS volumes.
+		Can be restricted to just one or a few resource types.`,
+		Example: `
+		aws-config drift --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive
+		aws-config drift --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive AWS::EC2::Instance
+		`,
+		Annotations: map[string]string{annotationRawConfiguration: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource := make(map[string]bool)
+			for _, arg := range args {
+				resource[arg] = true
+			}
+			hasRestrictions := len(resource) > 0
+			var results []driftRecord
+			for _, item := range items {
+				if hasRestrictions && !resource[item.ResourceType] {
+					continue
+				}
+				drift, err := item.AttributeDrift()
+				if err != nil {
+					return err
+				}
+				for _, d := range drift {
+					results = append(results, newDriftRecord(item, d))
+				}
+			}
+			sort.SliceStable(results, func(i, j int) bool {
+				if results[i].ResourceType != results[j].ResourceType {
+					return results[i].ResourceType < results[j].ResourceType
+				}
+				if results[i].ResourceID != results[j].ResourceID {
+					return results[i].ResourceID < results[j].ResourceID
+				}
+				return results[i].ARN < results[j].ARN
+			})
+
+			switch format {
+			case formatText:
+			case formatJSON:
+				if results == nil {
+					results = []driftRecord{}
+				}
+				return writeJSON(cmd.OutOrStdout(), kindDrift, results)
+			case formatNDJSON:
+				w := newNDJSONWriter(cmd.OutOrStdout())
+				for _, result := range results {
+					if err := w.Write(kindDrift, result); err != nil {
+						return err
+					}
+				}
+				return nil
+			default:
+				return fmt.Errorf("invalid format: %s", format)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "ResourceType ResourceID Attribute Config Terraform TerraformResource\n")
+			for _, result := range results {
+				entries := []string{result.ResourceType, result.ResourceID, result.Attribute, result.Config, result.Terraform, result.TerraformResource.String()}
+				for i, entry := range entries {
+					if entry == "" {
+						entries[i] = "-"
+					}
+				}
+				fmt.Fprintf(out, "%s\n", strings.Join(entries, " "))
+			}
+
+			// no error
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", formatText, "format for printing output, options are: "+strings.Join(formatOptions, " "))
+	return cmd
+}
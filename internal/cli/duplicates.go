@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/spf13/cobra"
+)
+
+func duplicates() *cobra.Command {
+	var format string
+	var formatOptions = []string{
+		formatText,
+		formatJSON,
+		formatNDJSON,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "duplicates",
+		Short: "list resources managed by more than one terraform resource",
+		Long: `List resources that are managed by more than one terraform resource instance,
+		whether in the same statefile or in different statefiles. Each such resource is at risk
+		of being changed or destroyed by one state without the knowledge of the other.
+		Can be restricted to just one or a few resource types.`,
+		Example: `
+		aws-config duplicates --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive
+		aws-config duplicates --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive AWS::EC2::Volume
+		`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource := make(map[string]bool)
+			for _, arg := range args {
+				resource[arg] = true
+			}
+			hasRestrictions := len(resource) > 0
+			var results []*compare.LocatedItem
+			for _, item := range items {
+				if !item.Duplicate() {
+					continue
+				}
+				if !hasRestrictions || resource[item.ResourceType] {
+					results = append(results, item)
+				}
+			}
+			sort.Slice(results, func(i, j int) bool {
+				if results[i].ResourceType != results[j].ResourceType {
+					return results[i].ResourceType < results[j].ResourceType
+				}
+				if results[i].ResourceID != results[j].ResourceID {
+					return results[i].ResourceID < results[j].ResourceID
+				}
+				return results[i].ARN < results[j].ARN
+			})
+
+			switch format {
+			case formatText:
+			case formatJSON:
+				records := make([]itemRecord, 0, len(results))
+				for _, item := range results {
+					records = append(records, newItemRecord(item))
+				}
+				return writeJSON(cmd.OutOrStdout(), kindItem, records)
+			case formatNDJSON:
+				w := newNDJSONWriter(cmd.OutOrStdout())
+				for _, item := range results {
+					if err := w.Write(kindItem, newItemRecord(item)); err != nil {
+						return err
+					}
+				}
+				return nil
+			default:
+				return fmt.Errorf("invalid format: %s", format)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "ResourceType ResourceID ARN TerraformResources\n")
+			for _, item := range results {
+				var addresses []string
+				for _, resource := range item.TerraformResources() {
+					addresses = append(addresses, resource.String())
+				}
+				entries := []string{item.ResourceType, item.ResourceID, item.ARN}
+				for i, entry := range entries {
+					if entry == "" {
+						entries[i] = "-"
+					}
+				}
+				fmt.Fprintf(out, "%s %s\n", strings.Join(entries, " "), strings.Join(addresses, ";"))
+			}
+
+			// no error
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", formatText, "format for printing output, options are: "+strings.Join(formatOptions, " "))
+	return cmd
+}
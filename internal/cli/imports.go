@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/spf13/cobra"
+)
+
+func imports() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "generate terraform import blocks for resources only in AWS Config",
+		Long: `Generate terraform 1.5+ import blocks for every resource of a mapped type that is only in
+		AWS Config, i.e. not managed by IaC, directly or via its parent, and not accepted by an ignore file.
+		Each is imported to the terraform type mapped to its AWS Config type, with a name derived from its
+		name, Name tag or ID, and with the import ID that the terraform type expects, e.g. the name of IAM
+		roles or the ARN of load balancers. Resources whose import ID cannot be determined are listed as comments.
+		Can be restricted to just one or a few resource types.`,
+		Example: `
+		aws-config import --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive > imports.tf
+		aws-config import --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive AWS::EC2::Instance > imports.tf
+		`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resource := make(map[string]bool)
+			for _, arg := range args {
+				resource[arg] = true
+			}
+			hasRestrictions := len(resource) > 0
+			out := cmd.OutOrStdout()
+			var count int
+			for _, imp := range compare.TerraformImports(items) {
+				if hasRestrictions && !resource[imp.Item.ResourceType] {
+					continue
+				}
+				if count > 0 {
+					fmt.Fprintln(out)
+				}
+				count++
+				id := imp.Item.ARN
+				if id == "" {
+					id = imp.Item.ResourceID
+				}
+				fmt.Fprintf(out, "# %s %s in %s\n", imp.Item.ResourceType, id, imp.Item.Scope())
+				fmt.Fprint(out, imp.ImportBlock())
+			}
+
+			// no error
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+// schemaVersion is the version of the structured (json and ndjson) output.
+// It must be incremented whenever a field is removed or changes meaning;
+// adding new fields does not require a new version.
+const schemaVersion = 1
+
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+
+	kindItem        = "item"
+	kindTypeSummary = "typeSummary"
+	kindSource      = "source"
+	kindSummary     = "summary"
+	kindTotals      = "totals"
+	kindCheck       = "check"
+	kindScope       = "scopeSummary"
+	kindDrift       = "drift"
+	kindTagResource = "tagResource"
+	kindTagTeam     = "tagTeam"
+)
+
+// envelope wraps every structured record, so that consumers can check the
+// schema version and the kind of record before decoding the data.
+type envelope struct {
+	SchemaVersion int         `json:"schemaVersion"`
+	Kind          string      `json:"kind"`
+	Data          interface{} `json:"data"`
+}
+
+// parentRecord is a single owner in the parent chain of an item.
+// Reason is why it owns the previous entry in the chain, or the item itself for the first entry.
+type parentRecord struct {
+	ResourceType string `json:"resourceType"`
+	ResourceName string `json:"resourceName,omitempty"`
+	ResourceID   string `json:"resourceId,omitempty"`
+	ARN          string `json:"arn,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// terraformResourceRecord is a terraform resource instance that matched an item.
+type terraformResourceRecord struct {
+	compare.TerraformResource
+	Address string `json:"address"`
+}
+
+// itemRecord is the structured representation of a single compare.LocatedItem.
+type itemRecord struct {
+	ResourceType        string                    `json:"resourceType"`
+	ResourceName        string                    `json:"resourceName,omitempty"`
+	ResourceID          string                    `json:"resourceId,omitempty"`
+	ARN                 string                    `json:"arn,omitempty"`
+	AccountID           string                    `json:"accountId,omitempty"`
+	Region              string                    `json:"region,omitempty"`
+	SourceFile          string                    `json:"sourceFile,omitempty"`
+	Status              string                    `json:"status,omitempty"`
+	CaptureTime         *time.Time                `json:"captureTime,omitempty"`
+	CreationTime        *time.Time                `json:"creationTime,omitempty"`
+	Owned               bool                      `json:"owned"`
+	MappedType          bool                      `json:"mappedType"`
+	Duplicate           bool                      `json:"duplicate"`
+	Sources             map[string]bool           `json:"sources"`
+	Accepted            bool                      `json:"accepted"`
+	AcceptedReason      string                    `json:"acceptedReason,omitempty"`
+	OwnershipReason     string                    `json:"ownershipReason,omitempty"`
+	Parents             []parentRecord            `json:"parents,omitempty"`
+	TerraformStatefiles []string                  `json:"terraformStatefiles,omitempty"`
+	TerraformResources  []terraformResourceRecord `json:"terraformResources,omitempty"`
+	IaCResources        []compare.IaCResource     `json:"iacResources,omitempty"`
+	// Attributes the values at each path requested with --attribute, keyed by the path
+	Attributes map[string][]interface{} `json:"attributes,omitempty"`
+}
+
+// driftRecord is a single attribute of an item that differs between AWS Config and terraform.
+type driftRecord struct {
+	ResourceType      string                  `json:"resourceType"`
+	ResourceName      string                  `json:"resourceName,omitempty"`
+	ResourceID        string                  `json:"resourceId,omitempty"`
+	ARN               string                  `json:"arn,omitempty"`
+	AccountID         string                  `json:"accountId,omitempty"`
+	Region            string                  `json:"region,omitempty"`
+	Attribute         string                  `json:"attribute"`
+	Config            string                  `json:"config"`
+	Terraform         string                  `json:"terraform"`
+	TerraformResource terraformResourceRecord `json:"terraformResource"`
+}
+
+// totalsRecord holds the overall counts of the summary, used as the final ndjson record.
+type totalsRecord struct {
+	BothResources      int            `json:"bothResources"`
+	SingleResources    int            `json:"singleResources"`
+	DuplicateResources int            `json:"duplicateResources"`
+	AcceptedResources  int            `json:"acceptedResources"`
+	TerraformFiles     int            `json:"terraformFiles"`
+	SkippedProviders   map[string]int `json:"skippedProviders"`
+	DeletedResources   int            `json:"deletedResources"`
+}
+
+// summaryRecord is the full summary, along with the count of terraform files.
+type summaryRecord struct {
+	*compare.Summary
+	TerraformFiles   int            `json:"terraformFiles"`
+	SkippedProviders map[string]int `json:"skippedProviders"`
+	DeletedResources int            `json:"deletedResources"`
+}
+
+// scopeTypeSummary is the summary of a single resource type, within an account and/or region.
+type scopeTypeSummary struct {
+	compare.Scope
+	compare.TypeSummary
+}
+
+func newParentRecord(item *compare.LocatedItem, reason string) parentRecord {
+	return parentRecord{
+		ResourceType: item.ResourceType,
+		ResourceName: item.ResourceName,
+		ResourceID:   item.ResourceID,
+		ARN:          item.ARN,
+		Reason:       reason,
+	}
+}
+
+func newItemRecord(item *compare.LocatedItem) itemRecord {
+	record := itemRecord{
+		ResourceType:        item.ResourceType,
+		ResourceName:        item.ResourceName,
+		ResourceID:          item.ResourceID,
+		ARN:                 item.ARN,
+		AccountID:           item.AccountID,
+		Region:              item.Region,
+		SourceFile:          item.SourceFile,
+		Status:              item.Status,
+		CaptureTime:         timePointer(item.CaptureTime),
+		CreationTime:        timePointer(item.CreationTime),
+		Owned:               item.Owned(),
+		MappedType:          item.MappedType(),
+		Duplicate:           item.Duplicate(),
+		OwnershipReason:     item.OwnershipReason(),
+		Sources:             make(map[string]bool),
+		TerraformStatefiles: item.Statefiles(),
+	}
+	for _, key := range sourceKeys {
+		record.Sources[key] = item.Source(key)
+	}
+	if rule := item.AcceptedBy(); rule != nil {
+		record.Accepted = true
+		record.AcceptedReason = rule.Justification
+	}
+	record.IaCResources = item.IaCResources()
+	for _, resource := range item.TerraformResources() {
+		record.TerraformResources = append(record.TerraformResources, terraformResourceRecord{
+			TerraformResource: resource,
+			Address:           resource.Address(),
+		})
+	}
+	child := item
+	for _, parent := range item.Parents() {
+		record.Parents = append(record.Parents, newParentRecord(parent, child.OwnershipReason()))
+		child = parent
+	}
+	return record
+}
+
+func newDriftRecord(item *compare.LocatedItem, drift compare.AttributeDrift) driftRecord {
+	return driftRecord{
+		ResourceType: item.ResourceType,
+		ResourceName: item.ResourceName,
+		ResourceID:   item.ResourceID,
+		ARN:          item.ARN,
+		AccountID:    item.AccountID,
+		Region:       item.Region,
+		Attribute:    drift.Attribute,
+		Config:       drift.Config,
+		Terraform:    drift.Terraform,
+		TerraformResource: terraformResourceRecord{
+			TerraformResource: drift.TerraformResource,
+			Address:           drift.TerraformResource.Address(),
+		},
+	}
+}
+
+// writeJSON write a single indented envelope holding all of the data.
+func writeJSON(w io.Writer, kind string, data interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(envelope{SchemaVersion: schemaVersion, Kind: kind, Data: data})
+}
+
+// ndjsonWriter writes one envelope per line.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) Write(kind string, data interface{}) error {
+	return n.enc.Encode(envelope{SchemaVersion: schemaVersion, Kind: kind, Data: data})
+}
+
+// ownerChain format the chain of owners of an item as text, e.g.
+// "ASG instance: AWS::AutoScaling::AutoScalingGroup/my-asg > CloudFormation stack contains: AWS::CloudFormation::Stack/my-stack"
+func ownerChain(item *compare.LocatedItem) string {
+	var links []string
+	child := item
+	for _, parent := range item.Parents() {
+		id := parent.ResourceID
+		if id == "" {
+			id = parent.ARN
+		}
+		if id == "" {
+			id = parent.ResourceName
+		}
+		links = append(links, fmt.Sprintf("%s: %s/%s", child.OwnershipReason(), parent.ResourceType, id))
+		child = parent
+	}
+	return strings.Join(links, " > ")
+}
+
+// timePointer the time, or nil if it is unknown, so that it is omitted
+func timePointer(t load.Timestamp) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
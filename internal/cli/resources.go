@@ -11,9 +11,10 @@ import (
 
 func resources() *cobra.Command {
 	var (
-		descending bool
-		sortBy     string
-		top        int
+		descending     bool
+		sortBy, format string
+		groupBy        string
+		top            int
 	)
 
 	const (
@@ -29,6 +30,11 @@ func resources() *cobra.Command {
 		sortByCountBoth,
 		sortByCountSingleOnly,
 	}
+	var formatOptions = []string{
+		formatText,
+		formatJSON,
+		formatNDJSON,
+	}
 
 	cmd := &cobra.Command{
 		Use:     "resources",
@@ -36,56 +42,92 @@ func resources() *cobra.Command {
 		Long:    `Show count of individual resource types in AWS Config snapshot and terraform files.`,
 		Example: `  aws-config resources --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate>`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			summary, err := compare.Summarize(items)
-			if err != nil {
-				return fmt.Errorf("unable to summarize: %w", err)
+			var groups []compare.ScopeSummary
+			if groupBy == "" {
+				summary, err := compare.Summarize(items)
+				if err != nil {
+					return fmt.Errorf("unable to summarize: %w", err)
+				}
+				groups = []compare.ScopeSummary{{Summary: summary}}
+			} else {
+				var err error
+				if groups, err = compare.SummarizeByScope(items, groupBy); err != nil {
+					return fmt.Errorf("unable to summarize: %w", err)
+				}
 			}
 
-			// sort the summary
-			sort.Slice(summary.ByType, func(i, j int) bool {
-				var retVal bool
-				switch sortBy {
-				case sortByCountTotal:
-					retVal = summary.ByType[i].Count < summary.ByType[j].Count
-				case sortByCountBoth:
-					retVal = summary.ByType[i].Both < summary.ByType[j].Both
-				case sortByCountSingleOnly:
-					retVal = summary.ByType[i].SingleOnly < summary.ByType[j].SingleOnly
-				case sortByResourceName:
-					retVal = summary.ByType[i].ResourceType < summary.ByType[j].ResourceType
-				default:
-					if strings.HasPrefix(sortBy, "count-") {
-						key := strings.TrimPrefix(sortBy, "count-")
-						retVal = summary.ByType[i].Source[key] < summary.ByType[j].Source[key]
-					} else {
+			var results []scopeTypeSummary
+			for _, group := range groups {
+				summary := group.Summary
+				// sort the summary
+				sort.Slice(summary.ByType, func(i, j int) bool {
+					var retVal bool
+					switch sortBy {
+					case sortByCountTotal:
+						retVal = summary.ByType[i].Count < summary.ByType[j].Count
+					case sortByCountBoth:
+						retVal = summary.ByType[i].Both < summary.ByType[j].Both
+					case sortByCountSingleOnly:
+						retVal = summary.ByType[i].SingleOnly < summary.ByType[j].SingleOnly
+					case sortByResourceName:
 						retVal = summary.ByType[i].ResourceType < summary.ByType[j].ResourceType
+					default:
+						if strings.HasPrefix(sortBy, "count-") {
+							key := strings.TrimPrefix(sortBy, "count-")
+							retVal = summary.ByType[i].Source[key] < summary.ByType[j].Source[key]
+						} else {
+							retVal = summary.ByType[i].ResourceType < summary.ByType[j].ResourceType
+						}
+					}
+					if descending {
+						retVal = !retVal
 					}
+					return retVal
+				})
+				// if limited, within each group
+				byType := summary.ByType
+				switch {
+				case top > 0 && top < len(byType):
+					byType = byType[:top]
+				case top < 0 && -top < len(byType):
+					byType = byType[len(byType)+top:]
 				}
-				if descending {
-					retVal = !retVal
+				for _, item := range byType {
+					results = append(results, scopeTypeSummary{Scope: group.Scope, TypeSummary: item})
 				}
-				return retVal
-			})
-			var results []compare.TypeSummary
-			// if limited
-			switch {
-			case top > 0:
-				results = summary.ByType[:top]
-			case top < 0:
-				results = summary.ByType[len(summary.ByType)+top:]
+			}
+
+			switch format {
+			case formatText:
+			case formatJSON:
+				return writeJSON(cmd.OutOrStdout(), kindTypeSummary, results)
+			case formatNDJSON:
+				w := newNDJSONWriter(cmd.OutOrStdout())
+				for _, item := range results {
+					if err := w.Write(kindTypeSummary, item); err != nil {
+						return err
+					}
+				}
+				return nil
 			default:
-				results = summary.ByType
+				return fmt.Errorf("invalid format: %s", format)
 			}
 
-			fmt.Printf("ResourceType Total Single-Only Both %s\n", strings.Join(compare.SourceKeys, " "))
+			if groupBy != "" {
+				fmt.Printf("Account Region ")
+			}
+			fmt.Printf("ResourceType Total Single-Only Both %s\n", strings.Join(sourceKeys, " "))
 			for _, item := range results {
+				if groupBy != "" {
+					fmt.Printf("%s %s ", orUnknown(item.AccountID), orUnknown(item.Region))
+				}
 				fmt.Printf("%s: %d %d %d ",
 					item.ResourceType,
 					item.Count,
 					item.SingleOnly,
 					item.Both,
 				)
-				for _, source := range compare.SourceKeys {
+				for _, source := range sourceKeys {
 					fmt.Printf("%d ", item.Source[source])
 				}
 				fmt.Println()
@@ -99,5 +141,15 @@ func resources() *cobra.Command {
 	cmd.Flags().BoolVar(&descending, "descending", false, "sort by descending instead of ascending; for by-type and detail")
 	cmd.Flags().StringVar(&sortBy, "sort", sortByDefault, "sort order for results, options are: "+strings.Join(sortOptions, " ")+", as well as 'count-<field>', where <field> is any supported field, e.g. terraform or eks; for by-type and detail")
 	cmd.Flags().IntVar(&top, "top", 0, "limit to the top x results, use 0 for all, negative for last; for by-type and detail")
+	cmd.Flags().StringVar(&format, "format", formatText, "format for printing output, options are: "+strings.Join(formatOptions, " "))
+	cmd.Flags().StringVar(&groupBy, "group-by", "", "count separately for each account and/or region, options are: "+strings.Join(compare.GroupByOptions, " ")+"; --top applies within each")
 	return cmd
 }
+
+// orUnknown the value, or "-" if it is empty
+func orUnknown(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,100 +1,279 @@
 package cli
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/fs"
 	"os"
-	"path"
-	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/iac-reconciler/aws-config/pkg/compare"
 	"github.com/iac-reconciler/aws-config/pkg/load"
+	"github.com/iac-reconciler/aws-config/pkg/sqlitestore"
 	"github.com/spf13/cobra"
 )
 
+// exit codes
+const (
+	exitError = 1
+	exitDrift = 2
+)
+
+const (
+	// annotationRawConfiguration annotation of commands that use the raw configuration of items
+	annotationRawConfiguration = "rawConfiguration"
+	// flagAttribute flag of paths to attributes of items, which may be in the raw configuration
+	flagAttribute = "attribute"
+)
+
 var (
 	rootCmd  = root()
 	verbose  bool
 	items    []*compare.LocatedItem
 	tfstates = make(map[string]load.TerraformState)
+	stats    compare.Stats
+	// terraformFiles the number of terraform states that were reconciled
+	terraformFiles int
+	// sourceKeys the sources for which there was input, which are shown in the output;
+	// config and terraform are always shown
+	sourceKeys = []string{compare.SourceConfig, compare.SourceTerraform}
+	// inventoryStore the store of --store or --from-store, if any, from which the configuration of
+	// the items is read, so it is only closed once the command is done
+	inventoryStore *sqlitestore.Store
+	// reconcileFlags the flags of the inputs that are reconciled, which --from-store replaces
+	reconcileFlags = []string{
+		"aws-config", "terraform", "tf-workspaces", "tf-s3-bucket", "tfc-organization", "tf-scope", "provider",
+		"rules", "disable-rule", "ignore", "cloudformation", "cdk-out", "pulumi", "crossplane",
+	}
 )
 
 func root() *cobra.Command {
 	var (
-		tfRecursive                 bool
-		snapshotFile, terraformPath string
+		tf                       stateFlags
+		snapshotFiles            []string
+		disabledRules, ruleFiles []string
+		ignoreFiles              []string
+		accounts, regions        []string
+		tfScopes                 map[string]string
+		providers                []string
+		cloudFormationFiles      []string
+		cdkOutDirs               []string
+		pulumiFiles              []string
+		crossplaneFiles          []string
+		storePath, fromStore     string
 	)
 	cmd := &cobra.Command{
 		Use: "aws-config",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			var (
-				tfstate []string
-				err     error
-				fsys    fs.FS
-			)
-			if tfRecursive {
-				fsys = os.DirFS(terraformPath)
-				if err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
-					if filepath.Ext(path) == ".tfstate" {
-						tfstate = append(tfstate, path)
+			if fromStore != "" {
+				if storePath != "" {
+					return errors.New("--store and --from-store cannot be used together")
+				}
+				for _, name := range reconcileFlags {
+					if cmd.Flags().Changed(name) {
+						return fmt.Errorf("--%s cannot be used with --from-store, whose inventory is already reconciled", name)
 					}
-					return nil
-				}); err != nil {
+				}
+				if err := loadInventory(fromStore); err != nil {
 					return err
 				}
-			} else {
-				fsys = os.DirFS(path.Dir(terraformPath))
-				tfstate = []string{path.Base(terraformPath)}
+				items = compare.FilterByScope(items, accounts, regions)
+				return nil
+			}
+			if len(snapshotFiles) == 0 {
+				return errors.New("--aws-config is required, unless the inventory is read with --from-store")
 			}
-			// read the config file
-			f, err := os.Open(snapshotFile)
+			var opts []compare.Option
+			// read the terraform states from every source
+			sources, err := tf.sources()
 			if err != nil {
-				return fmt.Errorf("unable to open snapshot file %s: %w", snapshotFile, err)
+				return err
+			}
+			for _, source := range sources {
+				if err := source.Load(cmd.Context(), tfstates); err != nil {
+					return err
+				}
+			}
+			// read the other IaC sources
+			if len(cloudFormationFiles) > 0 {
+				stackResources, err := load.LoadStackResources(cloudFormationFiles)
+				if err != nil {
+					return err
+				}
+				for _, dir := range cdkOutDirs {
+					if stackResources, err = load.ResolveCDKAssembly(dir, stackResources); err != nil {
+						return fmt.Errorf("unable to resolve cdk cloud assembly %s: %w", dir, err)
+					}
+				}
+				opts = append(opts, compare.WithCloudFormationStackResources(stackResources...))
+				sourceKeys = append(sourceKeys, compare.SourceCloudFormation)
+			}
+			if len(cdkOutDirs) > 0 && len(cloudFormationFiles) == 0 {
+				return errors.New("--cdk-out requires --cloudformation, to resolve the physical IDs of the resources")
+			}
+			if len(pulumiFiles) > 0 {
+				pulumiResources, err := load.LoadPulumiStacks(pulumiFiles)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, compare.WithPulumiResources(pulumiResources...))
+				sourceKeys = append(sourceKeys, compare.SourcePulumi)
 			}
-			defer f.Close()
-			var snapshot load.Snapshot
-			if err := json.NewDecoder(f).Decode(&snapshot); err != nil {
-				return fmt.Errorf("unable to decode snapshot file %s: %w", snapshotFile, err)
+			if len(crossplaneFiles) > 0 {
+				crossplaneResources, err := load.LoadCrossplaneResources(crossplaneFiles)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, compare.WithCrossplaneResources(crossplaneResources...))
+				sourceKeys = append(sourceKeys, compare.SourceCrossplane)
 			}
-			// read the tfstate files
-			for _, tfstateFile := range tfstate {
-				f, err := fsys.Open(tfstateFile)
+			sort.Strings(sourceKeys)
+			// read the ownership rules files
+			var rules []compare.OwnershipRule
+			for _, ruleFile := range ruleFiles {
+				f, err := os.Open(ruleFile)
 				if err != nil {
-					return fmt.Errorf("unable to open tfstate file %s: %w", tfstateFile, err)
+					return fmt.Errorf("unable to open rules file %s: %w", ruleFile, err)
 				}
 				defer f.Close()
-				var state load.TerraformState
-				if err := json.NewDecoder(f).Decode(&state); err != nil {
-					return fmt.Errorf("unable to decode terraform state file %s: %w", tfstateFile, err)
+				fileRules, err := compare.LoadOwnershipRules(f)
+				if err != nil {
+					return fmt.Errorf("unable to load rules file %s: %w", ruleFile, err)
 				}
-				tfstates[tfstateFile] = state
+				rules = append(rules, fileRules...)
 			}
-			// all loaded, now run the reconcile
-			items, err = compare.Reconcile(snapshot, tfstates)
+			// read the ignore files
+			var ignoreRules []*compare.IgnoreRule
+			for _, ignoreFile := range ignoreFiles {
+				f, err := os.Open(ignoreFile)
+				if err != nil {
+					return fmt.Errorf("unable to open ignore file %s: %w", ignoreFile, err)
+				}
+				defer f.Close()
+				fileRules, err := compare.LoadIgnoreRules(f)
+				if err != nil {
+					return fmt.Errorf("unable to load ignore file %s: %w", ignoreFile, err)
+				}
+				ignoreRules = append(ignoreRules, fileRules...)
+			}
+			// read the config snapshots, keeping the raw configuration only if it is used, as it is
+			// most of the size of large snapshots; a stored inventory keeps it for the commands that
+			// use it later, but the store writes it to the database as each item is read, so it is
+			// not kept in memory
+			var snapshotOpts []load.SnapshotOption
+			if !needsRawConfiguration(cmd, rules) && storePath == "" {
+				snapshotOpts = append(snapshotOpts, load.WithoutRawConfiguration())
+			}
+			opts = append(opts,
+				compare.WithDisabledOwnershipRules(disabledRules...),
+				compare.WithOwnershipRules(rules...),
+				compare.WithIgnoreRules(ignoreRules...),
+				compare.WithStats(&stats),
+			)
+			if len(providers) > 0 {
+				opts = append(opts, compare.WithProviderPatterns(providers...))
+			}
+			for tfstateFile, s := range tfScopes {
+				scope, err := compare.ParseScope(s)
+				if err != nil {
+					return fmt.Errorf("invalid --tf-scope for %s: %w", tfstateFile, err)
+				}
+				opts = append(opts, compare.WithTerraformScope(tfstateFile, scope))
+			}
+			if storePath != "" {
+				if inventoryStore, err = sqlitestore.Create(storePath); err != nil {
+					return err
+				}
+				opts = append(opts, compare.WithStore(inventoryStore))
+			}
+			// all loaded but the snapshots, which are reconciled as they are read
+			items, err = compare.ReconcileItems(func(fn func(item *load.ConfigurationItem) error) error {
+				_, err := load.StreamSnapshots(snapshotFiles, func(item load.ConfigurationItem) error {
+					return fn(&item)
+				}, snapshotOpts...)
+				return err
+			}, tfstates, opts...)
 			if err != nil {
 				return fmt.Errorf("unable to reconcile: %w", err)
 			}
+			terraformFiles = len(tfstates)
+			if inventoryStore != nil {
+				if err := saveInventory(inventoryStore); err != nil {
+					return err
+				}
+			}
+			items = compare.FilterByScope(items, accounts, regions)
 			return nil
 		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			if inventoryStore == nil {
+				return nil
+			}
+			return inventoryStore.Close()
+		},
 	}
 	cmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "print lots of output to stderr")
-	cmd.PersistentFlags().BoolVar(&tfRecursive, "tf-recursive", false, "treat the path to terraform state as a directory and recursively search for .tfstate files")
-	cmd.PersistentFlags().StringVar(&terraformPath, "terraform", "", "path to the terraform state file or directory containing .tfstate files; required")
-	cmd.PersistentFlags().StringVar(&snapshotFile, "aws-config", "", "path to the AWS Config snapshot json file; required")
-	_ = cmd.MarkPersistentFlagRequired("terraform")
-	_ = cmd.MarkPersistentFlagRequired("aws-config")
+	tf.register(cmd)
+	cmd.PersistentFlags().StringSliceVar(&snapshotFiles, "aws-config", nil, "path to an AWS Config snapshot json file, optionally gzipped, or to a directory in the AWS Config delivery layout AWSLogs/<account>/Config/<region>/YYYY/M/D/ConfigSnapshot/, of which the latest snapshot for each account and region is used; may be repeated, all are merged; required, unless --from-store")
+	cmd.PersistentFlags().StringSliceVar(&disabledRules, "disable-rule", nil, "ownership rule to disable, may be repeated or comma-separated; options are: "+strings.Join(compare.OwnershipRuleNames(), " "))
+	cmd.PersistentFlags().StringSliceVar(&ruleFiles, "rules", nil, "path to a yaml or json file of additional ownership rules, may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&ignoreFiles, "ignore", nil, "path to a yaml or json file of resources deliberately left unmanaged, reported as accepted rather than drift; may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&cloudFormationFiles, "cloudformation", nil, "path to the json output of aws cloudformation describe-stack-resources or list-stack-resources, whose resources are reconciled as the source cloudformation; may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&cdkOutDirs, "cdk-out", nil, "path to a cloud assembly synthesized by the CDK, e.g. cdk.out, whose stacks are resolved against the --cloudformation exports; may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&pulumiFiles, "pulumi", nil, "path to the output of pulumi stack export, whose resources are reconciled as the source pulumi; may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&crossplaneFiles, "crossplane", nil, "path to the output of kubectl get managed -o json, whose crossplane managed resources are reconciled as the source crossplane; may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&providers, "provider", nil, "glob of the source address of terraform providers whose resources are reconciled, e.g. registry.example.com/*/aws, may be repeated or comma-separated; replaces the defaults: "+strings.Join(compare.DefaultProviderPatterns, " "))
+	cmd.PersistentFlags().StringSliceVar(&accounts, "account", nil, "only include resources in this account ID, may be repeated or comma-separated")
+	cmd.PersistentFlags().StringSliceVar(&regions, "region", nil, "only include resources in this region, may be repeated or comma-separated; global resources, e.g. IAM, are in the region 'global'")
+	cmd.PersistentFlags().StringToStringVar(&tfScopes, "tf-scope", nil, "account and region of the resources in a terraform state file whose ARN does not include them, as <statefile>=<account>/<region>, where statefile is the name of the state, e.g. its path relative to --terraform, or s3://<bucket>/<key>; defaults to the most common in the ARNs of the file")
+	cmd.PersistentFlags().StringVar(&storePath, "store", "", "path to a SQLite database in which to keep the index while reconciling, rather than in memory, and then the reconciled inventory, for --from-store; any existing file is replaced")
+	cmd.PersistentFlags().StringVar(&fromStore, "from-store", "", "path to a SQLite database created with --store, whose inventory is used rather than reading the inputs and reconciling again")
 
 	return cmd
 }
 
+// activeSource whether there was input for the source, so that it is shown in the output
+func activeSource(name string) bool {
+	for _, key := range sourceKeys {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(summarize())
 	rootCmd.AddCommand(detail())
 	rootCmd.AddCommand(resources())
+	rootCmd.AddCommand(duplicates())
+	rootCmd.AddCommand(drift())
+	rootCmd.AddCommand(tags())
+	rootCmd.AddCommand(imports())
+	rootCmd.AddCommand(codegen())
+	rootCmd.AddCommand(check())
 }
 
-// Execute primary function for cobra
+// Execute primary function for cobra. Exits with exitError on any error, or exitDrift
+// if drift exceeds the thresholds of the check command.
 func Execute() {
-	_ = rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		var drift *driftError
+		if errors.As(err, &drift) {
+			os.Exit(exitDrift)
+		}
+		os.Exit(exitError)
+	}
+}
+
+// needsRawConfiguration whether the command uses the raw configuration of items: it is annotated
+// with annotationRawConfiguration, it has paths to attributes, or an ownership rule matches them
+func needsRawConfiguration(cmd *cobra.Command, rules []compare.OwnershipRule) bool {
+	if cmd.Annotations[annotationRawConfiguration] != "" {
+		return true
+	}
+	if f := cmd.Flags().Lookup(flagAttribute); f != nil && f.Changed {
+		return true
+	}
+	return compare.OwnershipRulesUseConfiguration(rules...)
 }
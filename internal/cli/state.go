@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+	"github.com/spf13/cobra"
+)
+
+// stateFlags the flags that select the sources of terraform state
+type stateFlags struct {
+	path          string
+	recursive     bool
+	workspaces    []string
+	s3Bucket      string
+	s3Prefix      string
+	s3Region      string
+	s3Endpoint    string
+	tfcAddress    string
+	tfcOrg        string
+	tfcWorkspaces []string
+}
+
+func (f *stateFlags) register(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.BoolVar(&f.recursive, "tf-recursive", false, "treat the path to terraform state as a directory and recursively search for .tfstate files")
+	flags.StringVar(&f.path, "terraform", "", "path to the terraform state file or directory containing .tfstate files")
+	flags.StringSliceVar(&f.workspaces, "tf-workspaces", nil, "path to a terraform configuration directory, whose local workspace states, terraform.tfstate and terraform.tfstate.d/*/terraform.tfstate, are all read; may be repeated")
+	flags.StringVar(&f.s3Bucket, "tf-s3-bucket", "", "S3 bucket of an s3 backend, from which every .tfstate object is read; credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN")
+	flags.StringVar(&f.s3Prefix, "tf-s3-prefix", "", "only read objects in --tf-s3-bucket under this prefix")
+	flags.StringVar(&f.s3Region, "tf-s3-region", "", "region of --tf-s3-bucket; defaults to AWS_REGION, AWS_DEFAULT_REGION, then us-east-1")
+	flags.StringVar(&f.s3Endpoint, "tf-s3-endpoint", "", "URL of S3-compatible storage, e.g. MinIO, in which --tf-s3-bucket is addressed by path")
+	flags.StringVar(&f.tfcOrg, "tfc-organization", "", "Terraform Cloud or Enterprise organization, from whose workspaces the current states are read; the token is read from TF_TOKEN_<hostname> or TFE_TOKEN")
+	flags.StringSliceVar(&f.tfcWorkspaces, "tfc-workspace", nil, "only read these workspaces of --tfc-organization, may be repeated or comma-separated")
+	flags.StringVar(&f.tfcAddress, "tfc-address", load.DefaultTFCAddress, "address of Terraform Enterprise")
+}
+
+// sources the state sources selected by the flags; at least one is required
+func (f *stateFlags) sources() ([]load.StateSource, error) {
+	var sources []load.StateSource
+	if f.path != "" {
+		sources = append(sources, load.LocalStateSource{Path: f.path, Recursive: f.recursive})
+	}
+	for _, dir := range f.workspaces {
+		sources = append(sources, load.WorkspaceStateSource{Dir: dir})
+	}
+	if f.s3Bucket != "" {
+		region := firstNonEmpty(f.s3Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+		sources = append(sources, load.S3StateSource{
+			Bucket:          f.s3Bucket,
+			Prefix:          f.s3Prefix,
+			Region:          region,
+			Endpoint:        f.s3Endpoint,
+			PathStyle:       f.s3Endpoint != "",
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+	}
+	if f.tfcOrg != "" {
+		sources = append(sources, load.TFCStateSource{
+			Address:      f.tfcAddress,
+			Token:        firstNonEmpty(os.Getenv(tfcTokenEnv(f.tfcAddress)), os.Getenv("TFE_TOKEN")),
+			Organization: f.tfcOrg,
+			Workspaces:   f.tfcWorkspaces,
+		})
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no terraform state, one of --terraform, --tf-workspaces, --tf-s3-bucket or --tfc-organization is required")
+	}
+	return sources, nil
+}
+
+// tfcTokenEnv the environment variable from which terraform itself reads the token for the host
+// of the address, e.g. TF_TOKEN_app_terraform_io
+func tfcTokenEnv(address string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	return "TF_TOKEN_" + strings.NewReplacer(".", "_", "-", "__").Replace(host)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/iac-reconciler/aws-config/pkg/sqlitestore"
+)
+
+// metadata of the inventory in a store, which the commands need besides the items
+const (
+	metadataStats          = "stats"
+	metadataTerraformFiles = "terraformFiles"
+	metadataSourceKeys     = "sourceKeys"
+)
+
+// saveInventory save the reconciled items to the store, with what the commands need to report on
+// them, and commit them, so that they can be used with --from-store even if the command fails
+func saveInventory(store *sqlitestore.Store) error {
+	if err := store.SaveInventory(items); err != nil {
+		return err
+	}
+	for key, value := range map[string]interface{}{
+		metadataStats:          stats,
+		metadataTerraformFiles: terraformFiles,
+		metadataSourceKeys:     sourceKeys,
+	} {
+		if err := store.SetMetadata(key, value); err != nil {
+			return err
+		}
+	}
+	return store.Commit()
+}
+
+// loadInventory load the items saved by saveInventory from the store at the path, which is kept
+// open as inventoryStore, as the configuration of the items is read from it as it is used
+func loadInventory(path string) error {
+	store, err := sqlitestore.Open(path)
+	if err != nil {
+		return err
+	}
+	if items, err = store.LoadInventory(); err != nil {
+		store.Close()
+		return fmt.Errorf("unable to load inventory from %s: %w", path, err)
+	}
+	inventoryStore = store
+	for key, value := range map[string]interface{}{
+		metadataStats:          &stats,
+		metadataTerraformFiles: &terraformFiles,
+		metadataSourceKeys:     &sourceKeys,
+	} {
+		if _, err := store.Metadata(key, value); err != nil {
+			return err
+		}
+	}
+	sort.Strings(sourceKeys)
+	return nil
+}
@@ -2,35 +2,158 @@ package cli
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/iac-reconciler/aws-config/pkg/compare"
 	"github.com/spf13/cobra"
 )
 
 func summarize() *cobra.Command {
+	var format, groupBy string
+	var formatOptions = []string{
+		formatText,
+		formatJSON,
+		formatNDJSON,
+	}
 	cmd := &cobra.Command{
 		Use:     "summarize",
 		Short:   "Summarize the resources by source",
 		Long:    `Summarize the resources by source.`,
 		Example: `  aws-config summarize --aws-config <aws-config-snapshot.json> --terraform <terraform.tfstate>`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if groupBy != "" {
+				return summarizeByScope(cmd, format, groupBy)
+			}
 			summary, err := compare.Summarize(items)
 			if err != nil {
 				return fmt.Errorf("unable to summarize: %w", err)
 			}
 
-			fmt.Printf("Summary:\n")
-			fmt.Printf("Both (Config+IaC): %d\n", summary.BothResources)
-			fmt.Printf("Source All Only Mapped Unmapped\n")
-			for _, source := range summary.Sources {
-				fmt.Printf("%s: %d %d %d %d\n", source.Name, source.Total, source.OnlyCount, source.OnlyMappedCount, source.OnlyUnmappedCount)
+			switch format {
+			case formatText:
+			case formatJSON:
+				return writeJSON(cmd.OutOrStdout(), kindSummary, summaryRecord{Summary: summary, TerraformFiles: terraformFiles, SkippedProviders: stats.SkippedProviders, DeletedResources: stats.DeletedResources})
+			case formatNDJSON:
+				w := newNDJSONWriter(cmd.OutOrStdout())
+				for _, source := range summary.Sources {
+					if err := w.Write(kindSource, source); err != nil {
+						return err
+					}
+				}
+				for _, item := range summary.ByType {
+					if err := w.Write(kindTypeSummary, item); err != nil {
+						return err
+					}
+				}
+				return w.Write(kindTotals, totalsRecord{
+					BothResources:      summary.BothResources,
+					SingleResources:    summary.SingleResources,
+					DuplicateResources: summary.DuplicateResources,
+					AcceptedResources:  summary.AcceptedResources,
+					TerraformFiles:     terraformFiles,
+					SkippedProviders:   stats.SkippedProviders,
+					DeletedResources:   stats.DeletedResources,
+				})
+			default:
+				return fmt.Errorf("invalid format: %s", format)
 			}
-			fmt.Printf("Terraform Files: %d\n", len(tfstates))
+
+			fmt.Printf("Summary:\n")
+			printSummary(summary)
+			fmt.Printf("Terraform Files: %d\n", terraformFiles)
+			fmt.Printf("Managed by multiple terraform resources: %d\n", summary.DuplicateResources)
+			printSkippedProviders()
 
 			// no error
 			return nil
 		},
 	}
 
+	cmd.Flags().StringVar(&format, "format", formatText, "format for printing output, options are: "+strings.Join(formatOptions, " "))
+	cmd.Flags().StringVar(&groupBy, "group-by", "", "summarize separately for each account and/or region, options are: "+strings.Join(compare.GroupByOptions, " "))
 	return cmd
 }
+
+// summarizeByScope print a separate summary for each account and/or region
+func summarizeByScope(cmd *cobra.Command, format, groupBy string) error {
+	summaries, err := compare.SummarizeByScope(items, groupBy)
+	if err != nil {
+		return fmt.Errorf("unable to summarize: %w", err)
+	}
+	switch format {
+	case formatText:
+	case formatJSON:
+		return writeJSON(cmd.OutOrStdout(), kindScope, summaries)
+	case formatNDJSON:
+		w := newNDJSONWriter(cmd.OutOrStdout())
+		for _, summary := range summaries {
+			if err := w.Write(kindScope, summary); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid format: %s", format)
+	}
+	for _, summary := range summaries {
+		fmt.Printf("Summary for %s:\n", scopeName(summary.Scope, groupBy))
+		printSummary(summary.Summary)
+		fmt.Printf("Managed by multiple terraform resources: %d\n", summary.Summary.DuplicateResources)
+		fmt.Println()
+	}
+	fmt.Printf("Terraform Files: %d\n", terraformFiles)
+	printSkippedProviders()
+	return nil
+}
+
+func printSummary(summary *compare.Summary) {
+	fmt.Printf("Both (Config+IaC): %d\n", summary.BothResources)
+	fmt.Printf("Accepted (deliberately unmanaged): %d\n", summary.AcceptedResources)
+	fmt.Printf("Source All Only Mapped Unmapped\n")
+	for _, source := range summary.Sources {
+		if !activeSource(source.Name) {
+			continue
+		}
+		fmt.Printf("%s: %d %d %d %d\n", source.Name, source.Total, source.OnlyCount, source.OnlyMappedCount, source.OnlyUnmappedCount)
+	}
+}
+
+// scopeName human-readable name of a scope grouped by groupBy, where unknown fields are shown as such
+func scopeName(scope compare.Scope, groupBy string) string {
+	account, region := scope.AccountID, scope.Region
+	if account == "" {
+		account = "unknown account"
+	}
+	if region == "" {
+		region = "unknown region"
+	}
+	switch groupBy {
+	case compare.GroupByAccount:
+		return "account " + account
+	case compare.GroupByRegion:
+		return "region " + region
+	default:
+		return "account " + account + ", region " + region
+	}
+}
+
+// printSkippedProviders print the count of IaC resources of each provider, and of deleted
+// resources in AWS Config, that were not reconciled
+func printSkippedProviders() {
+	if stats.DeletedResources > 0 {
+		fmt.Printf("Deleted in AWS Config, not reconciled: %d\n", stats.DeletedResources)
+	}
+	if len(stats.SkippedProviders) == 0 {
+		return
+	}
+	var sources []string
+	for source := range stats.SkippedProviders {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	fmt.Printf("Skipped IaC resources by provider:\n")
+	for _, source := range sources {
+		fmt.Printf("%s: %d\n", source, stats.SkippedProviders[source])
+	}
+}
@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/spf13/cobra"
+)
+
+const defaultTeamTag = "team"
+
+// tagRecord is a single resource that does not comply with the tag policy.
+type tagRecord struct {
+	ResourceType string `json:"resourceType"`
+	ResourceName string `json:"resourceName,omitempty"`
+	ResourceID   string `json:"resourceId,omitempty"`
+	ARN          string `json:"arn,omitempty"`
+	AccountID    string `json:"accountId,omitempty"`
+	Region       string `json:"region,omitempty"`
+	compare.TagReport
+}
+
+// tagTeamRecord is the tag compliance of the resources of a single team.
+type tagTeamRecord struct {
+	Team string `json:"team"`
+	// Resources the number of resources whose tags were checked
+	Resources int `json:"resources"`
+	// NonCompliant the number of resources that do not comply, by source
+	NonCompliant map[string]int `json:"nonCompliant"`
+	// Mismatched the number of resources whose tags differ between terraform and AWS Config
+	Mismatched int         `json:"mismatched"`
+	Items      []tagRecord `json:"items,omitempty"`
+}
+
+func tags() *cobra.Command {
+	var (
+		policyFile string
+		teamTag    string
+		format     string
+	)
+	var formatOptions = []string{
+		formatText,
+		formatJSON,
+		formatNDJSON,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "tags",
+		Short: "report resources that do not comply with a required-tag policy",
+		Long: `Report the resources whose tags, in AWS Config or in terraform, do not comply with a
+		required-tag policy, i.e. that are missing a required tag, or whose value is not allowed.
+		Resources whose terraform tags_all differ from their tags in AWS Config are reported as well.
+		Results are grouped by the value of the team tag.`,
+		Example: `
+		aws-config tags --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive --policy tag-policy.yaml
+		aws-config tags --aws-config <aws-config-snapshot.json> --terraform <terraform/root> --tf-recursive --policy tag-policy.yaml --team-tag owner
+		`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch format {
+			case formatText, formatJSON, formatNDJSON:
+			default:
+				return fmt.Errorf("invalid format: %s", format)
+			}
+			f, err := os.Open(policyFile)
+			if err != nil {
+				return fmt.Errorf("unable to open tag policy file %s: %w", policyFile, err)
+			}
+			defer f.Close()
+			policy, err := compare.LoadTagPolicy(f)
+			if err != nil {
+				return fmt.Errorf("unable to load tag policy file %s: %w", policyFile, err)
+			}
+
+			teams := make(map[string]*tagTeamRecord)
+			for _, item := range items {
+				if !policy.Applies(item) {
+					continue
+				}
+				report, ok := policy.Check(item, teamTag)
+				if !ok {
+					continue
+				}
+				team, ok := teams[report.Team]
+				if !ok {
+					team = &tagTeamRecord{Team: report.Team, NonCompliant: make(map[string]int)}
+					teams[report.Team] = team
+				}
+				team.Resources++
+				if report.Compliant() {
+					continue
+				}
+				sources := make(map[string]bool)
+				for _, violation := range report.Violations {
+					sources[violation.Source] = true
+				}
+				for source := range sources {
+					team.NonCompliant[source]++
+				}
+				if len(report.Mismatched) > 0 {
+					team.Mismatched++
+				}
+				team.Items = append(team.Items, tagRecord{
+					ResourceType: item.ResourceType,
+					ResourceName: item.ResourceName,
+					ResourceID:   item.ResourceID,
+					ARN:          item.ARN,
+					AccountID:    item.AccountID,
+					Region:       item.Region,
+					TagReport:    report,
+				})
+			}
+			results := make([]tagTeamRecord, 0, len(teams))
+			for _, team := range teams {
+				sort.Slice(team.Items, func(i, j int) bool {
+					if team.Items[i].ResourceType != team.Items[j].ResourceType {
+						return team.Items[i].ResourceType < team.Items[j].ResourceType
+					}
+					if team.Items[i].ResourceID != team.Items[j].ResourceID {
+						return team.Items[i].ResourceID < team.Items[j].ResourceID
+					}
+					return team.Items[i].ARN < team.Items[j].ARN
+				})
+				results = append(results, *team)
+			}
+			sort.Slice(results, func(i, j int) bool {
+				return results[i].Team < results[j].Team
+			})
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case formatJSON:
+				return writeJSON(out, kindTagTeam, results)
+			case formatNDJSON:
+				w := newNDJSONWriter(out)
+				for _, team := range results {
+					for _, item := range team.Items {
+						if err := w.Write(kindTagResource, item); err != nil {
+							return err
+						}
+					}
+					team.Items = nil
+					if err := w.Write(kindTagTeam, team); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			for i, team := range results {
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				fmt.Fprintf(out, "Team: %s\n", orUnknown(team.Team))
+				fmt.Fprintf(out, "Resources: %d\n", team.Resources)
+				for _, source := range []string{compare.SourceConfig, compare.SourceTerraform} {
+					fmt.Fprintf(out, "Non-compliant in %s: %d\n", source, team.NonCompliant[source])
+				}
+				fmt.Fprintf(out, "Mismatched between terraform and config: %d\n", team.Mismatched)
+				if len(team.Items) == 0 {
+					continue
+				}
+				fmt.Fprintf(out, "ResourceType ResourceID Source Problem Tags\n")
+				for _, item := range team.Items {
+					id := item.ResourceID
+					if id == "" {
+						id = "-"
+					}
+					for _, violation := range item.Violations {
+						source := violation.Source
+						if violation.TerraformResource != nil {
+							source = violation.TerraformResource.String()
+						}
+						if len(violation.Missing) > 0 {
+							fmt.Fprintf(out, "%s %s %s missing %s\n", item.ResourceType, id, source, strings.Join(violation.Missing, ","))
+						}
+						if len(violation.Invalid) > 0 {
+							fmt.Fprintf(out, "%s %s %s invalid %s\n", item.ResourceType, id, source, strings.Join(violation.Invalid, ","))
+						}
+					}
+					if len(item.Mismatched) > 0 {
+						fmt.Fprintf(out, "%s %s %s,%s mismatched %s\n", item.ResourceType, id, compare.SourceConfig, compare.SourceTerraform, strings.Join(item.Mismatched, ","))
+					}
+				}
+			}
+
+			// no error
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&policyFile, "policy", "", "path to the required-tag policy file, in yaml or json")
+	cmd.Flags().StringVar(&teamTag, "team-tag", defaultTeamTag, "tag whose value is the team that owns a resource, by which results are grouped")
+	cmd.Flags().StringVar(&format, "format", formatText, "format for printing output, options are: "+strings.Join(formatOptions, " "))
+	_ = cmd.MarkFlagRequired("policy")
+	return cmd
+}
@@ -0,0 +1,385 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iac-reconciler/aws-config/pkg/load"
+	log "github.com/sirupsen/logrus"
+)
+
+// names of the built-in ownership rules
+const (
+	RuleRDSENI             = "rds-eni"
+	RuleEKSENI             = "eks-eni"
+	RuleInstanceENI        = "ec2-instance-eni"
+	RuleVPCEndpointENI     = "vpc-endpoint-eni"
+	RuleEBSVolume          = "ebs-volume"
+	RuleASGInstance        = "asg-instance"
+	RuleELBAlarm           = "elb-alarm"
+	RuleEC2FleetTemplate   = "ec2-fleet-launch-template"
+	RuleEKSSecurityGroup   = "eks-security-group"
+	RuleServiceLinkedRole  = "service-linked-role"
+	RuleEKSELB             = "eks-elb"
+	RuleEKSELBV2           = "eks-elbv2"
+	RuleRDSClusterSnapshot = "rds-cluster-snapshot"
+	RuleLambdaENI          = "lambda-eni"
+	RuleELBENI             = "elb-eni"
+	RuleNATGatewayENI      = "nat-gateway-eni"
+	RuleElastiCacheENI     = "elasticache-eni"
+	RuleTransitGatewayENI  = "transit-gateway-eni"
+)
+
+// the order matters: when more than one rule finds an owner for the same item,
+// the last one wins.
+var builtinOwnershipRules = []OwnershipRule{
+	ownershipRuleFunc{name: RuleRDSENI, types: []string{resourceTypeENI}, apply: rdsENIOwner},
+	ownershipRuleFunc{name: RuleEKSENI, types: []string{resourceTypeENI}, apply: eksENIOwner},
+	ownershipRuleFunc{name: RuleInstanceENI, types: []string{resourceTypeEC2Instance}, apply: instanceENIOwner},
+	ownershipRuleFunc{name: RuleVPCEndpointENI, types: []string{resourceTypeVPCEndpoint}, apply: vpcEndpointENIOwner},
+	ownershipRuleFunc{name: RuleEBSVolume, types: []string{resourceTypeEBSVolume}, apply: ebsVolumeOwner},
+	ownershipRuleFunc{name: RuleASGInstance, types: []string{resourceTypeASG}, apply: asgInstanceOwner},
+	ownershipRuleFunc{name: RuleELBAlarm, types: []string{resourceTypeAlarm}, apply: elbAlarmOwner},
+	ownershipRuleFunc{name: RuleEC2FleetTemplate, types: []string{resourceTypeEC2Fleet}, apply: ec2FleetOwner},
+	ownershipRuleFunc{name: RuleEKSSecurityGroup, types: []string{resourceTypeSecurityGroup}, apply: eksClusterTagOwner},
+	ownershipRuleFunc{name: RuleServiceLinkedRole, types: []string{resourceTypeIAMRole}, apply: serviceLinkedRoleOwner},
+	ownershipRuleFunc{name: RuleEKSELB, types: []string{resourceTypeELB}, apply: eksClusterTagOwner},
+	ownershipRuleFunc{name: RuleEKSELBV2, types: []string{resourceTypeELBV2}, apply: eksELBV2Owner},
+	ownershipRuleFunc{name: RuleRDSClusterSnapshot, types: []string{resourceTypeRDSClusterSnapshot}, apply: rdsClusterSnapshotOwner},
+	ownershipRuleFunc{name: RuleLambdaENI, types: []string{resourceTypeENI}, apply: lambdaENIOwner},
+	ownershipRuleFunc{name: RuleELBENI, types: []string{resourceTypeENI}, apply: elbENIOwner},
+	ownershipRuleFunc{name: RuleNATGatewayENI, types: []string{resourceTypeENI}, apply: natGatewayENIOwner},
+	ownershipRuleFunc{name: RuleElastiCacheENI, types: []string{resourceTypeENI}, apply: elastiCacheENIOwner},
+	ownershipRuleFunc{name: RuleTransitGatewayENI, types: []string{resourceTypeENI}, apply: transitGatewayENIOwner},
+}
+
+func init() {
+	for _, rule := range builtinOwnershipRules {
+		if err := RegisterOwnershipRule(rule); err != nil {
+			log.Fatalf("unable to register builtin ownership rule: %v", err)
+		}
+	}
+}
+
+// owned convenience function to return a single ownership, if the parent was found
+func owned(child, parent *LocatedItem, reason string) []Ownership {
+	if parent == nil {
+		return nil
+	}
+	return []Ownership{{Child: child, Parent: parent, Reason: reason}}
+}
+
+// eksClusterOwnerTag get the name of the EKS cluster that owns the item via its tags, if any
+func eksClusterOwnerTag(tags map[string]string) string {
+	for tagName, tagValue := range tags {
+		if strings.HasPrefix(tagName, eksClusterOwnerTagNamePrefix) && tagValue == ownedTagValue {
+			return strings.TrimPrefix(tagName, eksClusterOwnerTagNamePrefix)
+		}
+	}
+	return ""
+}
+
+// handle RDS instance-owned ENIs; which, unfortunately, are not tagged on either side
+// who would believe it?
+func rdsENIOwner(item *LocatedItem, idx Index) []Ownership {
+	if item.Configuration.Description != rdsENI {
+		return nil
+	}
+	return owned(item, &LocatedItem{
+		ConfigurationItem: &load.ConfigurationItem{
+			ResourceType: resourceTypeRDSInstance,
+		},
+	}, OwnershipReasonRDSENI)
+}
+
+// EKS-created ENIs, or ENIs created by an EKS node
+func eksENIOwner(item *LocatedItem, idx Index) []Ownership {
+	var (
+		eniTag bool
+		nodeId string
+	)
+	clusterName := eksClusterOwnerTag(item.Tags)
+	for tagName, tagValue := range item.Tags {
+		if tagName == eksEniOwnerTagName && tagValue == eksEniOwnerTagValue {
+			eniTag = true
+		}
+		if tagName == k8sInstanceTag {
+			nodeId = tagValue
+		}
+	}
+	switch {
+	case eniTag && clusterName != "":
+		// this is an EKS-created ENI
+		// find the parent, and mark it
+		if parent, ok := idx.Get(resourceTypeEksCluster, clusterName); ok {
+			return owned(item, parent, OwnershipReasonEKSClusterTag)
+		}
+	case nodeId != "":
+		// this is a EC2 instance-created ENI
+		// find the parent, and mark it
+		if parent, ok := idx.Get(resourceTypeEC2Instance, nodeId); ok {
+			return owned(item, parent, OwnershipReasonInstanceTag)
+		}
+	}
+	return nil
+}
+
+// ENIs attached to EC2 instances
+func instanceENIOwner(item *LocatedItem, idx Index) []Ownership {
+	var ownerships []Ownership
+	for _, rel := range item.Relationships {
+		if rel.ResourceType != resourceTypeENI {
+			continue
+		}
+		if eni, ok := idx.Get(resourceTypeENI, rel.ResourceID); ok {
+			ownerships = append(ownerships, owned(eni, item, OwnershipReasonInstanceENI)...)
+		}
+	}
+	return ownerships
+}
+
+// VPC-Endpoint-owned ENIs
+func vpcEndpointENIOwner(item *LocatedItem, idx Index) []Ownership {
+	var ownerships []Ownership
+	for _, eni := range item.Configuration.NetworkInterfaceIDs {
+		detail, ok := idx.Get(resourceTypeENI, eni)
+		if !ok {
+			log.Warnf("found unknown resource: %s %s", resourceTypeENI, eni)
+			continue
+		}
+		ownerships = append(ownerships, owned(detail, item, OwnershipReasonVPCEndpointENI)...)
+	}
+	return ownerships
+}
+
+// EC2-instance owned volumes, or explicitly owned by an EKS cluster
+func ebsVolumeOwner(item *LocatedItem, idx Index) []Ownership {
+	if clusterName := eksClusterOwnerTag(item.Tags); clusterName != "" {
+		if parent, ok := idx.Get(resourceTypeEksCluster, clusterName); ok {
+			return owned(item, parent, OwnershipReasonEKSClusterTag)
+		}
+		return nil
+	}
+
+	// indicate that it is owned by whatever it is attached to
+	var ownerships []Ownership
+	for _, resource := range item.Relationships {
+		if resource.ResourceType == "" {
+			log.Warnf("AWS Config snapshot: empty resource type for item %s", resource.ResourceID)
+			continue
+		}
+		// only care about those attached-to
+		if strings.TrimSpace(resource.Name) != resourceAttachedToInstance {
+			continue
+		}
+		key := resource.ResourceID
+		if key == "" {
+			key = resource.ResourceName
+		}
+		detail, ok := idx.Get(resource.ResourceType, key)
+		if !ok {
+			// try by name
+			if detail, ok = idx.GetByName(resource.ResourceType, key); !ok {
+				log.Warnf("found unknown resource: %s %s", resource.ResourceType, key)
+				continue
+			}
+		}
+		ownerships = append(ownerships, owned(item, detail, OwnershipReasonAttachedToInstance)...)
+	}
+	return ownerships
+}
+
+// ASG-owned ec2 instances
+func asgInstanceOwner(item *LocatedItem, idx Index) []Ownership {
+	var ownerships []Ownership
+	for _, instance := range item.Configuration.Instances {
+		detail, ok := idx.Get(resourceTypeEC2Instance, instance.InstanceID)
+		if !ok {
+			log.Warnf("found unknown resource: %s %s", resourceTypeEC2Instance, instance.InstanceID)
+			continue
+		}
+		ownerships = append(ownerships, owned(detail, item, OwnershipReasonASGInstance)...)
+	}
+	return ownerships
+}
+
+// cloudwatch alarms for an ELB
+func elbAlarmOwner(item *LocatedItem, idx Index) []Ownership {
+	if item.Configuration.Namespace != cloudWatchNamespaceELB {
+		return nil
+	}
+	var lbID string
+	for _, dim := range item.Configuration.Dimensions {
+		if dim.Name != dimensionLoadBalancerName {
+			continue
+		}
+		lbID = dim.Value
+		break
+	}
+	if elb, ok := idx.Get(resourceTypeELB, lbID); ok {
+		return owned(item, elb, OwnershipReasonELBAlarm)
+	}
+	return nil
+}
+
+// EC2Fleets can be owned by a LaunchTemplate
+func ec2FleetOwner(item *LocatedItem, idx Index) []Ownership {
+	var ownerships []Ownership
+	for _, ltConfig := range item.Configuration.LaunchTemplateConfigs {
+		if ltConfig.LaunchTemplateSpecification.LaunchTemplateID == "" {
+			continue
+		}
+		if lt, ok := idx.Get(resourceTypeLaunchTemplate, ltConfig.LaunchTemplateSpecification.LaunchTemplateID); ok {
+			ownerships = append(ownerships, owned(item, lt, OwnershipReasonLaunchTemplate)...)
+		}
+	}
+	return ownerships
+}
+
+// EKS-created items, marked by the cluster owner tag, e.g. SecurityGroups and ELBs
+func eksClusterTagOwner(item *LocatedItem, idx Index) []Ownership {
+	clusterName := eksClusterOwnerTag(item.Tags)
+	if clusterName == "" {
+		return nil
+	}
+	if parent, ok := idx.Get(resourceTypeEksCluster, clusterName); ok {
+		return owned(item, parent, OwnershipReasonEKSClusterTag)
+	}
+	return nil
+}
+
+// service-linked roles are owned by the service, which is created if it does not yet exist
+func serviceLinkedRoleOwner(item *LocatedItem, idx Index) []Ownership {
+	if !strings.HasPrefix(item.Configuration.Path, serviceLinkedRolePathPrefix) {
+		return nil
+	}
+	service := strings.TrimPrefix(item.Configuration.Path, serviceLinkedRolePathPrefix)
+	// trim the final / if it exists
+	service = strings.TrimSuffix(service, "/")
+
+	// this is the name of the role; create a parent for this IAM Role as that service
+	parent, ok := idx.Get(resourceTypeService, service)
+	if !ok {
+		parent = &LocatedItem{
+			ConfigurationItem: &load.ConfigurationItem{
+				ResourceType: resourceTypeService,
+				ResourceID:   service,
+			},
+		}
+		idx.Add(service, parent)
+	}
+	return owned(item, parent, OwnershipReasonServiceLinkedRole)
+}
+
+// EKS-created ELBv2, which use a different tag than other EKS-created items
+func eksELBV2Owner(item *LocatedItem, idx Index) []Ownership {
+	clusterName := item.Tags[eksELBCluster]
+	if clusterName == "" {
+		return nil
+	}
+	if parent, ok := idx.Get(resourceTypeEksCluster, clusterName); ok {
+		return owned(item, parent, OwnershipReasonEKSClusterTag)
+	}
+	// did not find it? try by name
+	if parent, ok := idx.GetByName(resourceTypeEksCluster, clusterName); ok {
+		return owned(item, parent, OwnershipReasonEKSClusterTag)
+	}
+	return nil
+}
+
+// RDS Cluster Snapshots are owned by the clusters
+func rdsClusterSnapshotOwner(item *LocatedItem, idx Index) []Ownership {
+	if cluster, ok := idx.Get(resourceTypeRDSCluster, item.Configuration.DBClusterIdentifier); ok {
+		return owned(item, cluster, OwnershipReasonRDSClusterSnapshot)
+	}
+	if cluster, ok := idx.GetByName(resourceTypeRDSCluster, item.Configuration.DBClusterIdentifier); ok {
+		return owned(item, cluster, OwnershipReasonRDSClusterSnapshot)
+	}
+	return nil
+}
+
+// ENIs created for a lambda function
+func lambdaENIOwner(item *LocatedItem, idx Index) []Ownership {
+	if item.Configuration.InterfaceType != lambdaInterfaceType || !strings.HasPrefix(item.Configuration.Description, lambdaPrefix) {
+		return nil
+	}
+	lambdaName := strings.TrimPrefix(item.Configuration.Description, lambdaPrefix)
+	// lambda also includes a UUID at the end, so we need to remove that
+	if len(lambdaName) > len(uuid.Nil.String()) {
+		// check if it finishes with a UUID
+		uuidSize := len(uuid.Nil.String())
+		if _, err := uuid.Parse(lambdaName[len(lambdaName)-uuidSize:]); err == nil {
+			lambdaName = lambdaName[:len(lambdaName)-uuidSize]
+			// remove last -
+			lambdaName = strings.TrimSuffix(lambdaName, "-")
+		}
+	}
+	// now find the correct lambda
+	if lambda, ok := idx.Get(resourceTypeLambda, lambdaName); ok {
+		return owned(item, lambda, OwnershipReasonLambdaENI)
+	}
+	return nil
+}
+
+// ENIs created for an ELB or ELBv2
+func elbENIOwner(item *LocatedItem, idx Index) []Ownership {
+	if !strings.HasPrefix(item.Configuration.Description, elbPrefix) ||
+		(item.Configuration.Association.IPOwnerID != awsELBOwner &&
+			item.Configuration.Attachment.InstanceOwnerID != awsELBOwner &&
+			item.Configuration.InterfaceType != nlb) {
+		return nil
+	}
+	// find the ELB that owns it, make it the parent
+	region := item.Region
+	account := item.AccountID
+	elbName := strings.TrimPrefix(item.Configuration.Description, elbPrefix)
+	// could be ELB or ELBv2; nothing in it indicates that it is, except perhaps the start of the name,
+	// so we might as well just check both
+	if elb, ok := idx.Get(resourceTypeELB, elbName); ok {
+		return owned(item, elb, OwnershipReasonELBENI)
+	}
+	if region != "" && account != "" {
+		nlbArn := fmt.Sprintf("%s:%s:%s:loadbalancer/%s", elbArnPrefix, region, account, elbName)
+		if elb, ok := idx.Get(resourceTypeELBV2, nlbArn); ok {
+			return owned(item, elb, OwnershipReasonELBENI)
+		}
+	}
+	return nil
+}
+
+// ENIs created for a NAT Gateway
+func natGatewayENIOwner(item *LocatedItem, idx Index) []Ownership {
+	if item.Configuration.InterfaceType != natGatewayInterfaceType || !strings.HasPrefix(item.Configuration.Description, natGatewayPrefix) {
+		return nil
+	}
+	itemName := strings.TrimPrefix(item.Configuration.Description, natGatewayPrefix)
+	if parent, ok := idx.Get(resourceTypeNATGateway, itemName); ok {
+		return owned(item, parent, OwnershipReasonNATGatewayENI)
+	}
+	return nil
+}
+
+// ENIs created for an ElastiCache Cluster
+func elastiCacheENIOwner(item *LocatedItem, idx Index) []Ownership {
+	if !strings.HasPrefix(item.Configuration.Description, elastiCachePrefix) {
+		return nil
+	}
+	itemName := strings.TrimPrefix(item.Configuration.Description, elastiCachePrefix)
+	if parent, ok := idx.Get(resourceTypeElastiCacheCluster, itemName); ok {
+		return owned(item, parent, OwnershipReasonElastiCacheENI)
+	}
+	return nil
+}
+
+// ENIs created for a Transit Gateway Attachment
+func transitGatewayENIOwner(item *LocatedItem, idx Index) []Ownership {
+	if item.Configuration.InterfaceType != transitGatewayInterfaceType || !strings.HasPrefix(item.Configuration.Description, transitGatewayPrefix) {
+		return nil
+	}
+	itemName := strings.TrimPrefix(item.Configuration.Description, transitGatewayPrefix)
+	if parent, ok := idx.Get(resourceTypeTransitGatewayAttachment, itemName); ok {
+		return owned(item, parent, OwnershipReasonTransitGatewayENI)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package compare
+
+import (
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+// cloudFormationCustomResource the prefix of custom resource types, which are not AWS resources
+const cloudFormationCustomResource = "Custom::"
+
+// cloudFormationInternalTypes resource types that only exist within CloudFormation
+var cloudFormationInternalTypes = map[string]bool{
+	"AWS::CloudFormation::CustomResource":      true,
+	"AWS::CloudFormation::WaitCondition":       true,
+	"AWS::CloudFormation::WaitConditionHandle": true,
+	"AWS::CDK::Metadata":                       true,
+}
+
+// cloudFormationResources convert CloudFormation stack resources to IaCResources. CloudFormation
+// and AWS Config use the same resource type names, so no mapping is needed; the account and region
+// are those of the stack, or of its CDK environment. The origin is the construct path, if any.
+func cloudFormationResources(stackResources []load.StackResource) []IaCResource {
+	var resources []IaCResource
+	for _, r := range stackResources {
+		if strings.HasPrefix(r.ResourceType, cloudFormationCustomResource) || cloudFormationInternalTypes[r.ResourceType] {
+			continue
+		}
+		resource := IaCResource{
+			Source:       SourceCloudFormation,
+			ResourceType: r.ResourceType,
+			ResourceID:   r.PhysicalResourceID,
+			Scope:        ScopeFromARN(r.StackID).merge(Scope{AccountID: r.AccountID, Region: r.Region}),
+			MappedType:   true,
+			Origin:       r.StackName + "/" + r.LogicalResourceID,
+		}
+		if r.ConstructPath != "" {
+			resource.Origin = r.ConstructPath
+		}
+		if strings.HasPrefix(r.PhysicalResourceID, "arn:") {
+			resource.ARN = r.PhysicalResourceID
+		}
+		resources = append(resources, resource)
+	}
+	return resources
+}
@@ -0,0 +1,291 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// routeOriginCreateRoute the origin of routes that were added to a route table, rather than
+	// created with it, e.g. the local route, or propagated from a virtual private gateway
+	routeOriginCreateRoute = "CreateRoute"
+)
+
+// codegenRenderer render the body of the terraform resource of an item from its configuration
+type codegenRenderer func(item *LocatedItem, config map[string]interface{}, body *hclBody) error
+
+// codegenRenderers the terraform types for which resource blocks can be generated
+var codegenRenderers = map[string]codegenRenderer{
+	"aws_security_group": renderSecurityGroup,
+	"aws_iam_role":       renderIAMRole,
+	"aws_iam_policy":     renderIAMPolicy,
+	"aws_s3_bucket":      renderS3Bucket,
+	"aws_route_table":    renderRouteTable,
+	"aws_ebs_volume":     renderEBSVolume,
+}
+
+// CodegenTypes the terraform types for which ResourceBlock can generate resource blocks, sorted.
+func CodegenTypes() []string {
+	types := make([]string, 0, len(codegenRenderers))
+	for t := range codegenRenderers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ImportBlock the terraform import block, or a comment if the import ID cannot be determined.
+func (t TerraformImport) ImportBlock() string {
+	if t.ID == "" {
+		return fmt.Sprintf("# no import ID can be determined for %s\n", t.Address())
+	}
+	return fmt.Sprintf("import {\n  to = %s\n  id = %s\n}\n", t.Address(), hclString(t.ID))
+}
+
+// ResourceBlock render the terraform resource block of the item from its configuration as recorded
+// by AWS Config. Returns false if the terraform type is not one of CodegenTypes, or if the item has
+// no configuration. The block is a starting point, which should be checked with terraform plan
+// after importing; attributes that AWS Config does not record are omitted.
+func (t TerraformImport) ResourceBlock() (string, bool, error) {
+	render, ok := codegenRenderers[t.Type]
+	if !ok {
+		return "", false, nil
+	}
+	detail, err := t.Item.Detail()
+	if err != nil {
+		return "", false, fmt.Errorf("unable to read configuration of %s: %w", t.Address(), err)
+	}
+	if len(detail.Configuration.Raw) == 0 {
+		return "", false, nil
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(detail.Configuration.Raw, &config); err != nil {
+		return "", false, fmt.Errorf("unable to decode configuration of %s: %w", t.Address(), err)
+	}
+	body := &hclBody{}
+	if err := render(t.Item, config, body); err != nil {
+		return "", false, fmt.Errorf("unable to render %s: %w", t.Address(), err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "resource %s %s {\n", hclString(t.Type), hclString(t.Name))
+	body.write(&b, 1)
+	b.WriteString("}\n")
+	return b.String(), true, nil
+}
+
+func renderSecurityGroup(item *LocatedItem, config map[string]interface{}, body *hclBody) error {
+	groupID := stringValue(config, "groupId")
+	body.attr("name", stringValue(config, "groupName"))
+	body.attr("description", stringValue(config, "description"))
+	body.attr("vpc_id", stringValue(config, "vpcId"))
+	for _, rules := range []struct {
+		block string
+		key   string
+	}{
+		{ingress, "ipPermissions"},
+		{egress, "ipPermissionsEgress"},
+	} {
+		for _, permission := range objectList(config, rules.key) {
+			rule := body.block(rules.block)
+			protocol := stringValue(permission, "ipProtocol")
+			var fromPort, toPort int64
+			if protocol != allProtocols {
+				fromPort, toPort = intAttribute(permission["fromPort"]), intAttribute(permission["toPort"])
+			}
+			rule.attr("from_port", fromPort)
+			rule.attr("to_port", toPort)
+			rule.attr("protocol", protocol)
+			var (
+				cidrs, ipv6CIDRs, groups, prefixLists []string
+				descriptions                          = make(map[string]bool)
+				self                                  bool
+			)
+			for _, r := range objectList(permission, "ipv4Ranges") {
+				cidrs = append(cidrs, stringValue(r, "cidrIp"))
+				descriptions[stringValue(r, "description")] = true
+			}
+			for _, r := range objectList(permission, "ipv6Ranges") {
+				ipv6CIDRs = append(ipv6CIDRs, stringValue(r, "cidrIpv6"))
+				descriptions[stringValue(r, "description")] = true
+			}
+			for _, pair := range objectList(permission, "userIdGroupPairs") {
+				descriptions[stringValue(pair, "description")] = true
+				if pair["groupId"] == groupID {
+					self = true
+					continue
+				}
+				groups = append(groups, stringValue(pair, "groupId"))
+			}
+			for _, p := range objectList(permission, "prefixListIds") {
+				prefixLists = append(prefixLists, stringValue(p, "prefixListId"))
+				descriptions[stringValue(p, "description")] = true
+			}
+			rule.attr("cidr_blocks", cidrs)
+			rule.attr("ipv6_cidr_blocks", ipv6CIDRs)
+			rule.attr("security_groups", groups)
+			rule.attr("prefix_list_ids", prefixLists)
+			if self {
+				rule.attr("self", true)
+			}
+			// a rule has a single description, but each of its sources has its own in AWS
+			if len(descriptions) == 1 {
+				for description := range descriptions {
+					rule.attr("description", description)
+				}
+			} else {
+				rule.comment("sources have different descriptions, which must be split into separate rules")
+			}
+		}
+	}
+	body.attr("tags", settableTags(item.Tags))
+	return nil
+}
+
+func renderIAMRole(item *LocatedItem, config map[string]interface{}, body *hclBody) error {
+	body.attr("name", stringValue(config, "roleName"))
+	body.attr("path", stringValue(config, "path"))
+	body.attr("description", stringValue(config, "description"))
+	if duration, ok := config["maxSessionDuration"].(float64); ok {
+		body.attr("max_session_duration", duration)
+	}
+	policy, err := policyDocument(stringValue(config, "assumeRolePolicyDocument"))
+	if err != nil {
+		return fmt.Errorf("assume role policy: %w", err)
+	}
+	body.attr("assume_role_policy", policy)
+	if boundary, ok := config["permissionsBoundary"].(map[string]interface{}); ok {
+		body.attr("permissions_boundary", stringValue(boundary, "permissionsBoundaryArn"))
+	}
+	for _, inline := range objectList(config, "rolePolicyList") {
+		policy, err := policyDocument(stringValue(inline, "policyDocument"))
+		if err != nil {
+			return fmt.Errorf("inline policy %s: %w", stringValue(inline, "policyName"), err)
+		}
+		block := body.block("inline_policy")
+		block.attr("name", stringValue(inline, "policyName"))
+		block.attr("policy", policy)
+	}
+	body.attr("tags", settableTags(item.Tags))
+	for _, attached := range objectList(config, "attachedManagedPolicies") {
+		body.comment("attached %s, which needs an aws_iam_role_policy_attachment", stringValue(attached, "policyArn"))
+	}
+	return nil
+}
+
+func renderIAMPolicy(item *LocatedItem, config map[string]interface{}, body *hclBody) error {
+	body.attr("name", stringValue(config, "policyName"))
+	body.attr("path", stringValue(config, "path"))
+	body.attr("description", stringValue(config, "description"))
+	for _, version := range objectList(config, "policyVersionList") {
+		if isDefault, _ := version["isDefaultVersion"].(bool); !isDefault {
+			continue
+		}
+		policy, err := policyDocument(stringValue(version, "document"))
+		if err != nil {
+			return fmt.Errorf("policy version %s: %w", stringValue(version, "versionId"), err)
+		}
+		body.attr("policy", policy)
+	}
+	body.attr("tags", settableTags(item.Tags))
+	return nil
+}
+
+func renderS3Bucket(item *LocatedItem, config map[string]interface{}, body *hclBody) error {
+	name := stringValue(config, "name")
+	if name == "" {
+		name = item.ResourceName
+	}
+	body.attr("bucket", name)
+	body.attr("tags", settableTags(item.Tags))
+	return nil
+}
+
+func renderRouteTable(item *LocatedItem, config map[string]interface{}, body *hclBody) error {
+	body.attr("vpc_id", stringValue(config, "vpcId"))
+	var vgws []string
+	for _, vgw := range objectList(config, "propagatingVgws") {
+		vgws = append(vgws, stringValue(vgw, "gatewayId"))
+	}
+	body.attr("propagating_vgws", vgws)
+	for _, route := range objectList(config, "routes") {
+		if stringValue(route, "origin") != routeOriginCreateRoute {
+			continue
+		}
+		block := body.block("route")
+		block.attr("cidr_block", stringValue(route, "destinationCidrBlock"))
+		block.attr("ipv6_cidr_block", stringValue(route, "destinationIpv6CidrBlock"))
+		block.attr("destination_prefix_list_id", stringValue(route, "destinationPrefixListId"))
+		// gateway IDs are either internet or virtual private gateways, or gateway VPC endpoints
+		if gateway := stringValue(route, "gatewayId"); strings.HasPrefix(gateway, "vpce-") {
+			block.attr("vpc_endpoint_id", gateway)
+		} else {
+			block.attr("gateway_id", gateway)
+		}
+		block.attr("egress_only_gateway_id", stringValue(route, "egressOnlyInternetGatewayId"))
+		block.attr("nat_gateway_id", stringValue(route, "natGatewayId"))
+		block.attr("transit_gateway_id", stringValue(route, "transitGatewayId"))
+		block.attr("vpc_peering_connection_id", stringValue(route, "vpcPeeringConnectionId"))
+		block.attr("network_interface_id", stringValue(route, "networkInterfaceId"))
+		block.attr("carrier_gateway_id", stringValue(route, "carrierGatewayId"))
+		block.attr("local_gateway_id", stringValue(route, "localGatewayId"))
+	}
+	body.attr("tags", settableTags(item.Tags))
+	return nil
+}
+
+func renderEBSVolume(item *LocatedItem, config map[string]interface{}, body *hclBody) error {
+	volumeType := stringValue(config, "volumeType")
+	body.attr("availability_zone", stringValue(config, "availabilityZone"))
+	body.attr("size", config["size"])
+	body.attr("type", volumeType)
+	// iops can only be set for provisioned iops and gp3 volumes, and throughput only for gp3
+	switch volumeType {
+	case "io1", "io2", "gp3":
+		body.attr("iops", config["iops"])
+	}
+	if volumeType == "gp3" {
+		body.attr("throughput", config["throughput"])
+	}
+	if encrypted, _ := config["encrypted"].(bool); encrypted {
+		body.attr("encrypted", true)
+		body.attr("kms_key_id", stringValue(config, "kmsKeyId"))
+	}
+	body.attr("snapshot_id", stringValue(config, "snapshotId"))
+	if multiAttach, _ := config["multiAttachEnabled"].(bool); multiAttach {
+		body.attr("multi_attach_enabled", true)
+	}
+	body.attr("tags", settableTags(item.Tags))
+	return nil
+}
+
+// policyDocument an IAM policy document, which AWS Config records URL-encoded, as indented json
+func policyDocument(encoded string) (hclHeredoc, error) {
+	if encoded == "" {
+		return "", nil
+	}
+	document, err := url.PathUnescape(encoded)
+	if err != nil {
+		return "", err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(document), "", hclIndent); err != nil {
+		return "", err
+	}
+	return hclHeredoc(indented.String()), nil
+}
+
+// objectList the objects in the list under the key of m, skipping anything else
+func objectList(m map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := m[key].([]interface{})
+	objects := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if object, ok := v.(map[string]interface{}); ok {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
@@ -39,7 +39,7 @@ const (
 	terraformIAMPolicyType               = "aws_iam_policy"
 	nlb                                  = "network_load_balancer"
 	eksClusterOwnerTagNamePrefix         = "kubernetes.io/cluster/"
-	owned                                = "owned"
+	ownedTagValue                        = "owned"
 	nameRoleAttached                     = "Is attached to Role"
 	awsELBOwner                          = "amazon-elb"
 	lambdaInterfaceType                  = "lambda"
@@ -60,8 +60,7 @@ const (
 	serviceLinkedRolePathPrefix          = "/aws-service-role/"
 	eksELBCluster                        = "elbv2.k8s.aws/cluster"
 
-	terraformAWSRegistryProvider      = `provider["registry.terraform.io/hashicorp/aws"]`
-	terraformAWSProvider              = "provider.aws"
+	terraformTypeSecurityGroup        = "aws_security_group"
 	terraformTypeSecurityGroupRule    = "aws_security_group_rule"
 	terraformTypeRoute                = "aws_route"
 	terraformTypeRolePolicyAttachment = "aws_iam_role_policy_attachment"
@@ -69,3 +68,25 @@ const (
 	terraformTypeASGAttachment        = "aws_autoscaling_attachment"
 	terraformTypeRoute53RecordSet     = "aws_route53_record"
 )
+
+// Reasons why a parent is considered to own an item, as returned by LocatedItem.OwnershipReason()
+const (
+	OwnershipReasonStackContains      = "CloudFormation stack contains"
+	OwnershipReasonBeanstalkContains  = "Elastic Beanstalk application contains"
+	OwnershipReasonRDSENI             = "RDS ENI description"
+	OwnershipReasonEKSClusterTag      = "EKS cluster tag"
+	OwnershipReasonInstanceTag        = "EC2 instance tag"
+	OwnershipReasonInstanceENI        = "EC2 instance ENI"
+	OwnershipReasonVPCEndpointENI     = "VPC endpoint ENI"
+	OwnershipReasonAttachedToInstance = "attached to EC2 instance"
+	OwnershipReasonASGInstance        = "ASG instance"
+	OwnershipReasonELBAlarm           = "ELB alarm"
+	OwnershipReasonLaunchTemplate     = "EC2 fleet launch template"
+	OwnershipReasonServiceLinkedRole  = "service-linked role"
+	OwnershipReasonRDSClusterSnapshot = "RDS cluster snapshot"
+	OwnershipReasonLambdaENI          = "Lambda ENI description"
+	OwnershipReasonELBENI             = "ELB ENI description"
+	OwnershipReasonNATGatewayENI      = "NAT gateway ENI description"
+	OwnershipReasonElastiCacheENI     = "ElastiCache ENI description"
+	OwnershipReasonTransitGatewayENI  = "transit gateway attachment ENI description"
+)
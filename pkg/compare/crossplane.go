@@ -0,0 +1,108 @@
+package compare
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// crossplaneExternalName the annotation with the name of the resource in AWS, usually its ID
+	crossplaneExternalName = "crossplane.io/external-name"
+	// crossplaneObservePolicy the management policy of resources that crossplane only reads
+	crossplaneObservePolicy = "Observe"
+	// crossplaneObserveOnlyPolicy the former, single management policy of the same
+	crossplaneObserveOnlyPolicy = "ObserveOnly"
+	// crossplaneProviderPrefix the prefix of the skipped provider of resources of other providers,
+	// e.g. crossplane:gcp.upbound.io
+	crossplaneProviderPrefix = "crossplane:"
+)
+
+// crossplaneAWSGroups the suffixes of the API groups of the AWS providers: the upbound
+// providers, e.g. ec2.aws.upbound.io, and the former community provider, e.g. ec2.aws.crossplane.io
+var crossplaneAWSGroups = []string{".aws.upbound.io", ".aws.crossplane.io"}
+
+// awsCrossplaneToConfigTypeMap maps types from crossplane, as <group>/<kind>, to config types
+//
+//go:embed crossplane_typemap.json
+var awsCrossplaneToConfigTypeMapJSON []byte
+
+var awsCrossplaneToConfigTypeMap map[string]string
+
+func init() {
+	awsCrossplaneToConfigTypeMap = make(map[string]string)
+	if err := json.Unmarshal(awsCrossplaneToConfigTypeMapJSON, &awsCrossplaneToConfigTypeMap); err != nil {
+		log.Fatalf("unable to unmarshal crossplane_typemap.json: %v", err)
+	}
+}
+
+// crossplaneConfigType the AWS Config type of a crossplane kind in an API group, and whether it is
+// mapped. Kinds are mapped by crossplane_typemap.json; otherwise, many kinds have the name of the
+// Config type in the service of the group, e.g. Queue in sqs.aws.upbound.io is AWS::SQS::Queue.
+func crossplaneConfigType(group, kind string) (string, bool) {
+	if configType, ok := awsCrossplaneToConfigTypeMap[group+"/"+kind]; ok {
+		return configType, true
+	}
+	service, _, _ := strings.Cut(group, ".")
+	if configType, ok := knownConfigType("AWS::" + service + "::" + kind); ok {
+		return configType, true
+	}
+	return group + "/" + kind, false
+}
+
+// crossplaneResources convert crossplane managed resources to IaCResources. Only resources of the
+// AWS providers, that crossplane manages rather than only observes, are converted; the number of
+// resources of other providers is returned by provider, e.g. crossplane:gcp.upbound.io.
+func crossplaneResources(resources []load.CrossplaneResource) ([]IaCResource, map[string]int) {
+	var (
+		converted []IaCResource
+		skipped   = make(map[string]int)
+	)
+	for _, r := range resources {
+		group, _, _ := strings.Cut(r.APIVersion, "/")
+		if !isCrossplaneAWSGroup(group) {
+			_, provider, _ := strings.Cut(group, ".")
+			skipped[crossplaneProviderPrefix+provider]++
+			continue
+		}
+		if r.Metadata.DeletionTimestamp != "" || crossplaneObserveOnly(r) {
+			continue
+		}
+		configType, mapped := crossplaneConfigType(group, r.Kind)
+		resource := IaCResource{
+			Source:       SourceCrossplane,
+			ResourceType: configType,
+			ResourceID:   r.Metadata.Annotations[crossplaneExternalName],
+			ARN:          stringValue(r.Status.AtProvider, "arn"),
+			MappedType:   mapped,
+			Origin:       strings.ToLower(r.Kind) + "." + group + "/" + r.Metadata.Name,
+		}
+		if resource.ResourceID == "" {
+			resource.ResourceID = stringValue(r.Status.AtProvider, "id")
+		}
+		resource.Scope = ScopeFromARN(resource.ARN).merge(Scope{Region: stringValue(r.Spec.ForProvider, "region")})
+		converted = append(converted, resource)
+	}
+	return converted, skipped
+}
+
+// isCrossplaneAWSGroup whether the API group is that of an AWS provider
+func isCrossplaneAWSGroup(group string) bool {
+	for _, suffix := range crossplaneAWSGroups {
+		if strings.HasSuffix(group, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// crossplaneObserveOnly whether crossplane only reads the resource, which is then managed elsewhere, if at all
+func crossplaneObserveOnly(r load.CrossplaneResource) bool {
+	if r.Spec.ManagementPolicy == crossplaneObserveOnlyPolicy {
+		return true
+	}
+	return len(r.Spec.ManagementPolicies) == 1 && r.Spec.ManagementPolicies[0] == crossplaneObservePolicy
+}
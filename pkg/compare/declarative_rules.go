@@ -0,0 +1,335 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// OwnershipRuleFile a file of declarative ownership rules, in yaml or json.
+type OwnershipRuleFile struct {
+	Rules []OwnershipRuleSpec `yaml:"rules" json:"rules"`
+}
+
+// OwnershipRuleSpec a declarative ownership rule: an item of one of the ResourceTypes, which
+// matches all of the matchers, is owned by the item described in Owner.
+type OwnershipRuleSpec struct {
+	Name          string    `yaml:"name" json:"name"`
+	ResourceType  string    `yaml:"resourceType" json:"resourceType"`
+	ResourceTypes []string  `yaml:"resourceTypes" json:"resourceTypes"`
+	Match         RuleMatch `yaml:"match" json:"match"`
+	Owner         RuleOwner `yaml:"owner" json:"owner"`
+	// Reason why the owner owns the item, as returned by LocatedItem.OwnershipReason(); defaults to the name
+	Reason string `yaml:"reason" json:"reason"`
+}
+
+// RuleMatch the matchers of a declarative rule; all that are set must match.
+// Each pattern is a regular expression, which is not anchored unless it
+// includes ^ or $; an empty pattern matches anything. Capture groups of all
+// matchers are numbered in the order tag key, tag value, description,
+// relationship name, relationship ID, attribute value.
+type RuleMatch struct {
+	Tag          *TagMatch          `yaml:"tag" json:"tag"`
+	Description  string             `yaml:"description" json:"description"`
+	Relationship *RelationshipMatch `yaml:"relationship" json:"relationship"`
+	Attribute    *AttributeMatch    `yaml:"attribute" json:"attribute"`
+}
+
+// TagMatch match any tag whose key and value both match.
+type TagMatch struct {
+	Key   string `yaml:"key" json:"key"`
+	Value string `yaml:"value" json:"value"`
+}
+
+// RelationshipMatch match any relationship of the given type, whose name and ID both match.
+type RelationshipMatch struct {
+	ResourceType string `yaml:"resourceType" json:"resourceType"`
+	Name         string `yaml:"name" json:"name"`
+	ID           string `yaml:"id" json:"id"`
+}
+
+// AttributeMatch match any value at the path in the item as recorded by AWS Config, e.g.
+// configuration.vpcId or supplementaryConfiguration.BucketPolicy.policyText; see load.ParsePath.
+type AttributeMatch struct {
+	Path  string `yaml:"path" json:"path"`
+	Value string `yaml:"value" json:"value"`
+}
+
+// RuleOwner the owner of a matched item.
+type RuleOwner struct {
+	ResourceType string `yaml:"resourceType" json:"resourceType"`
+	// ID of the owner, with $1 or ${1} replaced by the first capture group, $name or ${name} by a
+	// named capture group, etc. A $ followed by digits refers to the numbered group only, so $1abc
+	// is group 1 followed by abc; a name must start with a letter or underscore, and ${name} can be
+	// followed by any text. It is looked up by ID, then name, then ARN. Defaults to "$1".
+	ID string `yaml:"id" json:"id"`
+	// Create the owner if it cannot be found, rather than leaving the item unowned.
+	Create bool `yaml:"create" json:"create"`
+}
+
+// captureReference a reference to a capture group in RuleOwner.ID: a number, which is only the
+// leading digits, or a name
+var captureReference = regexp.MustCompile(`\$([0-9]+|[A-Za-z_]\w*)|\$\{(\w+)\}`)
+
+// LoadOwnershipRules load declarative ownership rules from yaml or json.
+func LoadOwnershipRules(r io.Reader) ([]OwnershipRule, error) {
+	var file OwnershipRuleFile
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to decode ownership rules: %w", err)
+	}
+	var rules []OwnershipRule
+	for i, spec := range file.Rules {
+		rule, err := NewDeclarativeOwnershipRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ownership rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// NewDeclarativeOwnershipRule create an OwnershipRule from its spec.
+func NewDeclarativeOwnershipRule(spec OwnershipRuleSpec) (OwnershipRule, error) {
+	rule := &declarativeRule{spec: spec}
+	if spec.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	if spec.ResourceType != "" {
+		rule.types = append(rule.types, spec.ResourceType)
+	}
+	rule.types = append(rule.types, spec.ResourceTypes...)
+	if len(rule.types) == 0 {
+		return nil, fmt.Errorf("rule %s: missing resourceType", spec.Name)
+	}
+	if spec.Owner.ResourceType == "" {
+		return nil, fmt.Errorf("rule %s: missing owner resourceType", spec.Name)
+	}
+	if spec.Match.Tag == nil && spec.Match.Description == "" && spec.Match.Relationship == nil && spec.Match.Attribute == nil {
+		return nil, fmt.Errorf("rule %s: must have at least one matcher", spec.Name)
+	}
+	if rule.spec.Owner.ID == "" {
+		rule.spec.Owner.ID = "$1"
+	}
+	if rule.spec.Reason == "" {
+		rule.spec.Reason = spec.Name
+	}
+	var err error
+	if spec.Match.Tag != nil {
+		if rule.tagKey, err = regexp.Compile(spec.Match.Tag.Key); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid tag key: %w", spec.Name, err)
+		}
+		if rule.tagValue, err = regexp.Compile(spec.Match.Tag.Value); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid tag value: %w", spec.Name, err)
+		}
+	}
+	if rule.description, err = regexp.Compile(spec.Match.Description); err != nil {
+		return nil, fmt.Errorf("rule %s: invalid description: %w", spec.Name, err)
+	}
+	if spec.Match.Relationship != nil {
+		if rule.relationshipName, err = regexp.Compile(spec.Match.Relationship.Name); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid relationship name: %w", spec.Name, err)
+		}
+		if rule.relationshipID, err = regexp.Compile(spec.Match.Relationship.ID); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid relationship id: %w", spec.Name, err)
+		}
+	}
+	if spec.Match.Attribute != nil {
+		if rule.attributePath, err = load.ParsePath(spec.Match.Attribute.Path); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid attribute path: %w", spec.Name, err)
+		}
+		if rule.attributeValue, err = regexp.Compile(spec.Match.Attribute.Value); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid attribute value: %w", spec.Name, err)
+		}
+	}
+	return rule, nil
+}
+
+// declarativeRule OwnershipRule created from an OwnershipRuleSpec
+type declarativeRule struct {
+	spec                             OwnershipRuleSpec
+	types                            []string
+	tagKey, tagValue                 *regexp.Regexp
+	description                      *regexp.Regexp
+	relationshipName, relationshipID *regexp.Regexp
+	attributePath                    load.Path
+	attributeValue                   *regexp.Regexp
+}
+
+// usesConfiguration whether the rule matches the configuration as recorded, for which it needs
+// the raw configuration of items
+func (d *declarativeRule) usesConfiguration() bool {
+	return d.spec.Match.Attribute != nil
+}
+
+// OwnershipRulesUseConfiguration whether any of the rules match the configuration as recorded,
+// which is not available if the snapshot was loaded with load.WithoutRawConfiguration().
+func OwnershipRulesUseConfiguration(rules ...OwnershipRule) bool {
+	for _, rule := range rules {
+		if d, ok := rule.(*declarativeRule); ok && d.usesConfiguration() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *declarativeRule) Name() string {
+	return d.spec.Name
+}
+
+func (d *declarativeRule) ResourceTypes() []string {
+	return d.types
+}
+
+func (d *declarativeRule) Apply(item *LocatedItem, idx Index) []Ownership {
+	captures := newCaptures()
+	if d.spec.Match.Tag != nil {
+		var (
+			found bool
+			keys  []string
+		)
+		// sorted, so that the same tag matches every time if more than one could
+		for key := range item.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := item.Tags[key]
+			keyMatch := d.tagKey.FindStringSubmatch(key)
+			valueMatch := d.tagValue.FindStringSubmatch(value)
+			if keyMatch == nil || valueMatch == nil {
+				continue
+			}
+			captures.add(d.tagKey, keyMatch)
+			captures.add(d.tagValue, valueMatch)
+			found = true
+			break
+		}
+		if !found {
+			return nil
+		}
+	}
+	if d.spec.Match.Description != "" {
+		match := d.description.FindStringSubmatch(item.Configuration.Description)
+		if match == nil {
+			return nil
+		}
+		captures.add(d.description, match)
+	}
+	if d.spec.Match.Relationship != nil {
+		var found bool
+		for _, rel := range item.Relationships {
+			if d.spec.Match.Relationship.ResourceType != "" && rel.ResourceType != d.spec.Match.Relationship.ResourceType {
+				continue
+			}
+			id := rel.ResourceID
+			if id == "" {
+				id = rel.ResourceName
+			}
+			nameMatch := d.relationshipName.FindStringSubmatch(strings.TrimSpace(rel.Name))
+			idMatch := d.relationshipID.FindStringSubmatch(id)
+			if nameMatch == nil || idMatch == nil {
+				continue
+			}
+			captures.add(d.relationshipName, nameMatch)
+			captures.add(d.relationshipID, idMatch)
+			found = true
+			break
+		}
+		if !found {
+			return nil
+		}
+	}
+	if d.spec.Match.Attribute != nil {
+		values, err := item.LookupString(d.attributePath)
+		if err != nil {
+			log.Debugf("ownership rule %s: %v", d.spec.Name, err)
+			return nil
+		}
+		var found bool
+		for _, value := range values {
+			match := d.attributeValue.FindStringSubmatch(value)
+			if match == nil {
+				continue
+			}
+			captures.add(d.attributeValue, match)
+			found = true
+			break
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	ownerType := d.spec.Owner.ResourceType
+	ownerID := captures.expand(d.spec.Owner.ID)
+	if ownerID == "" {
+		log.Debugf("ownership rule %s: empty owner id for %s %s", d.spec.Name, item.ResourceType, item.ResourceID)
+		return nil
+	}
+	if parent, ok := idx.Get(ownerType, ownerID); ok {
+		return owned(item, parent, d.spec.Reason)
+	}
+	if parent, ok := idx.GetByName(ownerType, ownerID); ok {
+		return owned(item, parent, d.spec.Reason)
+	}
+	if parent, ok := idx.GetByARN(ownerType, ownerID); ok {
+		return owned(item, parent, d.spec.Reason)
+	}
+	if !d.spec.Owner.Create {
+		return nil
+	}
+	parent := &LocatedItem{
+		ConfigurationItem: &load.ConfigurationItem{
+			ResourceType: ownerType,
+			ResourceID:   ownerID,
+		},
+	}
+	idx.Add(ownerID, parent)
+	return owned(item, parent, d.spec.Reason)
+}
+
+// captures capture groups of all of the matchers of a rule, numbered in order
+type captures struct {
+	numbered []string
+	named    map[string]string
+}
+
+func newCaptures() *captures {
+	// group 0 is unused, so that the first capture group is $1, as in regexp
+	return &captures{numbered: []string{""}, named: make(map[string]string)}
+}
+
+func (c *captures) add(re *regexp.Regexp, match []string) {
+	names := re.SubexpNames()
+	for i := 1; i < len(match); i++ {
+		c.numbered = append(c.numbered, match[i])
+		if names[i] != "" {
+			c.named[names[i]] = match[i]
+		}
+	}
+}
+
+// expand replace $1, ${1}, $name and ${name} in the template with the captured values;
+// unknown references are replaced with the empty string.
+func (c *captures) expand(template string) string {
+	return captureReference.ReplaceAllStringFunc(template, func(ref string) string {
+		name := strings.Trim(ref, "${}")
+		if i, err := strconv.Atoi(name); err == nil {
+			if i < len(c.numbered) {
+				return c.numbered[i]
+			}
+			return ""
+		}
+		return c.named[name]
+	})
+}
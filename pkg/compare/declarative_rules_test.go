@@ -0,0 +1,37 @@
+package compare
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCapturesExpand(t *testing.T) {
+	re := regexp.MustCompile(`^(?P<cluster>[a-z]+)-(\d+)$`)
+	c := newCaptures()
+	c.add(re, re.FindStringSubmatch("prod-42"))
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"numbered", "$1", "prod"},
+		{"numbered in braces", "${2}", "42"},
+		{"named", "$cluster", "prod"},
+		{"named in braces", "${cluster}-eks", "prod-eks"},
+		{"digits then text", "$1abc", "prodabc"},
+		{"digits then digits", "$12", ""},
+		{"name then text", "$cluster_x", ""},
+		{"several", "arn:$cluster:${2}:$2", "arn:prod:42:42"},
+		{"unknown group", "$3", ""},
+		{"unknown name", "${region}", ""},
+		{"no reference", "static", "static"},
+		{"group zero", "$0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.expand(tt.template); got != tt.want {
+				t.Errorf("expand(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
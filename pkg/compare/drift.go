@@ -0,0 +1,298 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+const (
+	// driftTags the prefix of the attribute of each tag, e.g. tags.Name
+	driftTags = "tags."
+	// driftIngress and driftEgress the attributes of the rules of security groups
+	driftIngress = "ipPermissions"
+	driftEgress  = "ipPermissionsEgress"
+	// allProtocols the protocol of rules that allow all traffic, for which ports are ignored
+	allProtocols = "-1"
+	// reservedTagPrefix the prefix of tags set by AWS, which cannot be set by terraform
+	reservedTagPrefix = "aws:"
+)
+
+// AttributeDrift a single attribute whose value differs between the configuration of an item in
+// AWS Config and the terraform resource instance that manages it. A value is empty if the
+// attribute is not set on that side, e.g. a tag that only exists in terraform. For the rules of
+// security groups, the values are the rules that are only on that side, separated by ;.
+type AttributeDrift struct {
+	// Attribute the name of the attribute in AWS Config, e.g. instanceType or tags.Name
+	Attribute         string            `json:"attribute"`
+	Config            string            `json:"config"`
+	Terraform         string            `json:"terraform"`
+	TerraformResource TerraformResource `json:"terraformResource"`
+}
+
+// driftField maps an attribute of the configuration in AWS Config to that of a terraform resource.
+type driftField struct {
+	// attribute the path of the attribute in AWS Config, relative to the configuration
+	attribute string
+	// terraform the names of the terraform attributes, of which the first that is set is compared,
+	// e.g. engine_version_actual before engine_version
+	terraform []string
+}
+
+// driftFields the attributes of the configuration compared for each terraform resource type, in
+// addition to tags
+var driftFields = map[string][]driftField{
+	"aws_instance": {
+		{"instanceType", []string{"instance_type"}},
+		{"imageId", []string{"ami"}},
+	},
+	"aws_ebs_volume": {
+		{"volumeType", []string{"type"}},
+		{"encrypted", []string{"encrypted"}},
+		{"kmsKeyId", []string{"kms_key_id"}},
+	},
+	"aws_db_instance": {
+		{"dBInstanceClass", []string{"instance_class"}},
+		{"engineVersion", []string{"engine_version_actual", "engine_version"}},
+		{"storageEncrypted", []string{"storage_encrypted"}},
+		{"kmsKeyId", []string{"kms_key_id"}},
+	},
+	"aws_rds_cluster": {
+		{"engineVersion", []string{"engine_version_actual", "engine_version"}},
+		{"storageEncrypted", []string{"storage_encrypted"}},
+		{"kmsKeyId", []string{"kms_key_id"}},
+	},
+	"aws_lambda_function": {
+		{"runtime", []string{"runtime"}},
+		{"memorySize", []string{"memory_size"}},
+	},
+}
+
+// driftPaths the parsed path of the attribute of each of driftFields, relative to the configuration
+var driftPaths = make(map[string]load.Path)
+
+func init() {
+	for _, fields := range driftFields {
+		for _, field := range fields {
+			driftPaths[field.attribute] = load.MustParsePath(field.attribute)
+		}
+	}
+}
+
+// AttributeDrift compare the configuration of the item in AWS Config with each terraform resource
+// instance that manages it directly, i.e. whose type maps to the type of the item. Only tags, the
+// rules of security groups, and the attributes in driftFields are compared; attributes that are
+// not set on both sides are not compared, except for tags. Returns nothing if the item is not in
+// AWS Config, or its configuration was not recorded, or it is not in terraform, and an error only
+// if its configuration cannot be read from its store.
+func (l *LocatedItem) AttributeDrift() ([]AttributeDrift, error) {
+	if !l.Source(SourceConfig) || !l.Recorded() || len(l.terraformResources) == 0 {
+		return nil, nil
+	}
+	detail, err := l.Detail()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		drift []AttributeDrift
+		// configuration the decoded configuration, only once there are fields to compare
+		configuration interface{}
+		decoded       bool
+	)
+	for _, resource := range l.terraformResources {
+		if resource.attributes == nil || awsTerraformToConfigTypeMap[resource.Type] != l.ResourceType {
+			continue
+		}
+		add := func(attribute, config, terraform string) {
+			drift = append(drift, AttributeDrift{
+				Attribute:         attribute,
+				Config:            config,
+				Terraform:         terraform,
+				TerraformResource: resource,
+			})
+		}
+		fields := driftFields[resource.Type]
+		if len(fields) > 0 && !decoded {
+			// a configuration that cannot be decoded has no fields to compare
+			if len(detail.Configuration.Raw) > 0 && json.Unmarshal(detail.Configuration.Raw, &configuration) != nil {
+				configuration = nil
+			}
+			decoded = true
+		}
+		for _, field := range fields {
+			// only scalar attributes, which are set, are compared
+			values := driftPaths[field.attribute].LookupString(configuration)
+			if len(values) != 1 || values[0] == "" {
+				continue
+			}
+			config := values[0]
+			for _, name := range field.terraform {
+				terraform, ok := attributeString(resource.attributes[name])
+				if !ok {
+					continue
+				}
+				if config != terraform {
+					add(field.attribute, config, terraform)
+				}
+				break
+			}
+		}
+		// AWS Config has no tags for types whose tags it does not record
+		if tags, ok := terraformTags(resource.attributes); ok && l.Tags != nil {
+			config := settableTags(l.Tags)
+			for _, key := range diffTags(config, tags) {
+				add(driftTags+key, config[key], tags[key])
+			}
+		}
+		if resource.Type == terraformTypeSecurityGroup {
+			for _, rules := range []struct {
+				attribute string
+				config    []load.IPPermission
+				terraform string
+			}{
+				{driftIngress, detail.Configuration.IPPermissions, ingress},
+				{driftEgress, detail.Configuration.IPPermissionsEgress, egress},
+			} {
+				terraform, ok := resource.attributes[rules.terraform].([]interface{})
+				if !ok {
+					continue
+				}
+				onlyConfig, onlyTerraform := diffSets(configRules(rules.config), terraformRules(terraform, l.ResourceID))
+				if len(onlyConfig) > 0 || len(onlyTerraform) > 0 {
+					add(rules.attribute, strings.Join(onlyConfig, ";"), strings.Join(onlyTerraform, ";"))
+				}
+			}
+		}
+	}
+	return drift, nil
+}
+
+// terraformTags the tags of a terraform resource instance: tags_all, which includes the default
+// tags of the provider, or tags for versions of the provider that do not have tags_all. Returns
+// false if the resource type has no tags.
+func terraformTags(attributes map[string]interface{}) (map[string]string, bool) {
+	raw, ok := attributes["tags_all"].(map[string]interface{})
+	if !ok {
+		raw, ok = attributes["tags"].(map[string]interface{})
+	}
+	if !ok {
+		// null, rather than empty, when there are no tags, e.g. in legacy states
+		_, ok = attributes["tags"]
+	}
+	tags := make(map[string]string, len(raw))
+	for key, value := range raw {
+		tags[key], _ = attributeString(value)
+	}
+	return tags, ok
+}
+
+// settableTags the tags that terraform can set, i.e. without those reserved by AWS, e.g.
+// aws:cloudformation:stack-name, which are in AWS Config but never in terraform
+func settableTags(tags map[string]string) map[string]string {
+	settable := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if !strings.HasPrefix(key, reservedTagPrefix) {
+			settable[key] = value
+		}
+	}
+	return settable
+}
+
+// diffTags the sorted keys of tags that differ, or that are in only one of the maps
+func diffTags(config, terraform map[string]string) []string {
+	var keys []string
+	for key, value := range config {
+		if other, ok := terraform[key]; !ok || other != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range terraform {
+		if _, ok := config[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configRules the rules of a security group in AWS Config, each as a single string for every
+// source, e.g. tcp:443-443:10.0.0.0/8, or -1:sg-123 for all traffic. Prefix lists are not compared.
+func configRules(permissions []load.IPPermission) map[string]bool {
+	rules := make(map[string]bool)
+	for _, p := range permissions {
+		for _, r := range p.IPV4Ranges {
+			rules[ruleString(p.IPProtocol, p.FromPort, p.ToPort, r.CIDRIP)] = true
+		}
+		for _, r := range p.IPV6Ranges {
+			cidr := r.CIDRIPv6
+			if cidr == "" {
+				cidr = r.CIDRIP
+			}
+			rules[ruleString(p.IPProtocol, p.FromPort, p.ToPort, cidr)] = true
+		}
+		for _, pair := range p.UserIDGroupPairs {
+			rules[ruleString(p.IPProtocol, p.FromPort, p.ToPort, pair.GroupID)] = true
+		}
+	}
+	return rules
+}
+
+// terraformRules the ingress or egress rules of an aws_security_group, in the form of configRules
+func terraformRules(blocks []interface{}, securityGroupID string) map[string]bool {
+	rules := make(map[string]bool)
+	for _, block := range blocks {
+		rule, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		protocol, _ := attributeString(rule["protocol"])
+		fromPort, toPort := intAttribute(rule["from_port"]), intAttribute(rule["to_port"])
+		var sources []string
+		for _, key := range []string{"cidr_blocks", "ipv6_cidr_blocks", "security_groups"} {
+			list, _ := rule[key].([]interface{})
+			for _, v := range list {
+				if source, ok := attributeString(v); ok {
+					// security groups of other accounts are <account>/<group id>
+					if i := strings.LastIndex(source, "/"); key == "security_groups" && i >= 0 {
+						source = source[i+1:]
+					}
+					sources = append(sources, source)
+				}
+			}
+		}
+		if self, _ := attributeString(rule["self"]); self == "true" {
+			sources = append(sources, securityGroupID)
+		}
+		for _, source := range sources {
+			rules[ruleString(protocol, fromPort, toPort, source)] = true
+		}
+	}
+	return rules
+}
+
+func ruleString(protocol string, fromPort, toPort int64, source string) string {
+	if protocol == allProtocols {
+		return fmt.Sprintf("%s:%s", protocol, source)
+	}
+	return fmt.Sprintf("%s:%d-%d:%s", protocol, fromPort, toPort, source)
+}
+
+// diffSets the sorted elements in only a, and those in only b
+func diffSets(a, b map[string]bool) (onlyA, onlyB []string) {
+	for k := range a {
+		if !b[k] {
+			onlyA = append(onlyA, k)
+		}
+	}
+	for k := range b {
+		if !a[k] {
+			onlyB = append(onlyB, k)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	return onlyA, onlyB
+}
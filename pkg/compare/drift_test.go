@@ -0,0 +1,84 @@
+package compare
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+func TestDiffSettableTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]string
+		terraform map[string]string
+		want      []string
+	}{
+		{"same", map[string]string{"env": "prod"}, map[string]string{"env": "prod"}, nil},
+		{"other value", map[string]string{"env": "prod"}, map[string]string{"env": "dev"}, []string{"env"}},
+		{"only in config", map[string]string{"env": "prod", "team": "a"}, map[string]string{"env": "prod"}, []string{"team"}},
+		{"only in terraform", map[string]string{}, map[string]string{"team": "a", "env": "prod"}, []string{"env", "team"}},
+		{
+			"reserved tags",
+			map[string]string{"env": "prod", "aws:cloudformation:stack-name": "app", "aws:autoscaling:groupName": "web"},
+			map[string]string{"env": "prod"},
+			nil,
+		},
+		{
+			"reserved prefix is case sensitive",
+			map[string]string{"AWS:team": "a"},
+			map[string]string{},
+			[]string{"AWS:team"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffTags(settableTags(tt.config), tt.terraform); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeDriftFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		attributes map[string]interface{}
+		want       []string
+	}{
+		{"same", `{"volumeType": "gp3", "encrypted": true}`, map[string]interface{}{"type": "gp3", "encrypted": true}, nil},
+		{"other value", `{"volumeType": "gp2", "encrypted": true}`, map[string]interface{}{"type": "gp3", "encrypted": true}, []string{"volumeType gp2 gp3"}},
+		{"legacy state", `{"volumeType": "gp3", "encrypted": false}`, map[string]interface{}{"type": "gp3", "encrypted": "true"}, []string{"encrypted false true"}},
+		{"not set in config", `{"volumeType": "gp3"}`, map[string]interface{}{"type": "gp3", "kms_key_id": "key"}, nil},
+		{"not set in terraform", `{"volumeType": "gp3", "kmsKeyId": "key"}`, map[string]interface{}{"type": "gp3"}, nil},
+		{"no configuration", ``, map[string]interface{}{"type": "gp3"}, nil},
+		{"invalid configuration", `{`, map[string]interface{}{"type": "gp3"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := &LocatedItem{
+				ConfigurationItem: &load.ConfigurationItem{
+					ResourceType:  "AWS::EC2::Volume",
+					ResourceID:    "vol-1",
+					Status:        load.StatusOK,
+					Configuration: load.Configuration{Raw: json.RawMessage(tt.raw)},
+				},
+				terraformResources: []TerraformResource{{Type: "aws_ebs_volume", Name: "v", attributes: tt.attributes}},
+			}
+			item.addSource(SourceConfig)
+			drift, err := item.AttributeDrift()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range drift {
+				got = append(got, d.Attribute+" "+d.Config+" "+d.Terraform)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AttributeDrift() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package compare
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const hclIndent = "  "
+
+// hclBody the attributes and nested blocks of a block of HCL, in order.
+type hclBody struct {
+	entries []hclEntry
+}
+
+// hclEntry a single attribute, nested block or comment of a body
+type hclEntry struct {
+	name    string
+	value   interface{}
+	block   *hclBody
+	comment string
+}
+
+// hclHeredoc a multi-line string, written as a heredoc
+type hclHeredoc string
+
+// attr add an attribute, unless the value is nil or empty, which terraform treats the same as unset.
+// Values may be strings, hclHeredoc, bools, numbers, []string, or map[string]string.
+func (b *hclBody) attr(name string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		if v == "" {
+			return
+		}
+	case hclHeredoc:
+		if v == "" {
+			return
+		}
+	case []string:
+		if len(v) == 0 {
+			return
+		}
+	case map[string]string:
+		if len(v) == 0 {
+			return
+		}
+	}
+	b.entries = append(b.entries, hclEntry{name: name, value: value})
+}
+
+// block add a nested block, and return its body
+func (b *hclBody) block(name string) *hclBody {
+	body := &hclBody{}
+	b.entries = append(b.entries, hclEntry{name: name, block: body})
+	return body
+}
+
+// comment add a comment line
+func (b *hclBody) comment(format string, args ...interface{}) {
+	b.entries = append(b.entries, hclEntry{comment: fmt.Sprintf(format, args...)})
+}
+
+// write the body at the given depth, aligning the = of each run of consecutive attributes, which
+// ends with any multi-line value, as terraform fmt does
+func (b *hclBody) write(w *strings.Builder, depth int) {
+	indent := strings.Repeat(hclIndent, depth)
+	widths := make([]int, len(b.entries))
+	for start := 0; start < len(b.entries); {
+		end, width := start, 0
+		for ; end < len(b.entries) && b.entries[end].block == nil && b.entries[end].comment == ""; end++ {
+			if len(b.entries[end].name) > width {
+				width = len(b.entries[end].name)
+			}
+			if isMultiline(b.entries[end].value) {
+				end++
+				break
+			}
+		}
+		if end == start {
+			end++
+		}
+		for i := start; i < end; i++ {
+			widths[i] = width
+		}
+		start = end
+	}
+	for i, entry := range b.entries {
+		switch {
+		case entry.comment != "":
+			fmt.Fprintf(w, "%s# %s\n", indent, entry.comment)
+		case entry.block != nil:
+			if i > 0 {
+				w.WriteString("\n")
+			}
+			fmt.Fprintf(w, "%s%s {\n", indent, entry.name)
+			entry.block.write(w, depth+1)
+			fmt.Fprintf(w, "%s}\n", indent)
+			if i < len(b.entries)-1 && b.entries[i+1].block == nil {
+				w.WriteString("\n")
+			}
+		default:
+			fmt.Fprintf(w, "%s%-*s = %s\n", indent, widths[i], entry.name, hclValue(entry.value, depth))
+		}
+	}
+}
+
+func isMultiline(value interface{}) bool {
+	switch value.(type) {
+	case map[string]string, hclHeredoc:
+		return true
+	}
+	return false
+}
+
+// hclValue format a value at the given depth
+func hclValue(value interface{}, depth int) string {
+	indent := strings.Repeat(hclIndent, depth)
+	switch v := value.(type) {
+	case string:
+		return hclString(v)
+	case hclHeredoc:
+		lines := strings.Split(strings.TrimRight(string(v), "\n"), "\n")
+		var b strings.Builder
+		b.WriteString("<<-EOT\n")
+		for _, line := range lines {
+			fmt.Fprintf(&b, "%s%s%s\n", indent, hclIndent, hclTemplateEscaper.Replace(line))
+		}
+		b.WriteString(indent + "EOT")
+		return b.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		quoted := make([]string, 0, len(v))
+		for _, s := range v {
+			quoted = append(quoted, hclString(s))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		width := 0
+		for k := range v {
+			keys = append(keys, k)
+			if len(hclString(k)) > width {
+				width = len(hclString(k))
+			}
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s%s%-*s = %s\n", indent, hclIndent, width, hclString(k), hclString(v[k]))
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// hclTemplateEscaper escape interpolation and template directives, which are in both quoted
+// strings and heredocs
+var hclTemplateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+// hclString quote a string for HCL
+func hclString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s)
+	return `"` + hclTemplateEscaper.Replace(s) + `"`
+}
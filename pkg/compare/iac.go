@@ -0,0 +1,83 @@
+package compare
+
+import (
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+// IaCResource a resource managed by an IaC tool other than terraform, e.g. a resource of a
+// CloudFormation stack, already mapped to the AWS Config resource type.
+type IaCResource struct {
+	// Source the name of the IaC source, e.g. SourceCloudFormation
+	Source       string `json:"source"`
+	ResourceType string `json:"resourceType"`
+	// ResourceID, ARN and Name identify the resource; at least one must be set. The item is
+	// found by ID, then name, then ARN, as IaC tools often call a name or ARN an ID.
+	ResourceID string `json:"resourceId,omitempty"`
+	ARN        string `json:"arn,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Scope the account and region of the resource, if known
+	Scope Scope `json:"scope"`
+	// MappedType indicates if the resource type is known to AWS Config
+	MappedType bool `json:"mappedType"`
+	// Origin where the resource is defined, e.g. <stack>/<logical id>, for display
+	Origin string `json:"origin"`
+}
+
+// IaCResources returns the resources of IaC other than terraform that matched the item.
+func (l LocatedItem) IaCResources() []IaCResource {
+	return l.iacResources
+}
+
+// reconcileIaCResource find the item for the resource, or create it if it is not in any other
+// source, and mark it as found in the source of the resource.
+func reconcileIaCResource(idx *index, resource IaCResource) {
+	resource.Scope = resource.Scope.forResource(resource.ResourceType, resource.ARN)
+	scoped := idx.scoped(resource.Scope)
+	key := resource.ResourceID
+	if key == "" {
+		key = resource.ARN
+	}
+	if key == "" {
+		key = resource.Name
+	}
+	if key == "" {
+		return
+	}
+	item, ok := scoped.Get(resource.ResourceType, key)
+	if !ok && resource.Name != "" {
+		item, ok = scoped.GetByName(resource.ResourceType, resource.Name)
+	}
+	if !ok && resource.ResourceID != "" {
+		// the ID is the name for many resource types, e.g. IAM roles
+		item, ok = scoped.GetByName(resource.ResourceType, resource.ResourceID)
+	}
+	if !ok && resource.ARN != "" {
+		item, ok = scoped.GetByARN(resource.ResourceType, resource.ARN)
+	}
+	if !ok && resource.ResourceID != "" {
+		// ... and the ARN for others, e.g. SNS topics
+		item, ok = scoped.GetByARN(resource.ResourceType, resource.ResourceID)
+	}
+	if !ok {
+		item = &LocatedItem{
+			ConfigurationItem: &load.ConfigurationItem{
+				ResourceType: resource.ResourceType,
+				ResourceID:   resource.ResourceID,
+				ResourceName: resource.Name,
+				ARN:          resource.ARN,
+				AccountID:    resource.Scope.AccountID,
+				Region:       resource.Scope.Region,
+			},
+			mappedType: resource.MappedType,
+		}
+		scoped.Add(key, item)
+		if resource.ARN != "" {
+			idx.addARN(resource.ARN, item)
+		}
+		if resource.Name != "" {
+			idx.addName(resource.Name, item)
+		}
+	}
+	item.addSource(resource.Source)
+	item.iacResources = append(item.iacResources, resource)
+}
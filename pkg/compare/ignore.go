@@ -0,0 +1,159 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const ignoreExpiresLayout = "2006-01-02"
+
+// IgnoreFile a file of resources that are deliberately left unmanaged, in yaml or json.
+type IgnoreFile struct {
+	Ignore []IgnoreRule `yaml:"ignore" json:"ignore"`
+}
+
+// IgnoreRule matches resources that are deliberately left unmanaged, which are reported as
+// accepted rather than as drift. Every matcher that is set must match. Each matcher is a glob,
+// where * matches any sequence of characters, including /, and ? matches any single character.
+type IgnoreRule struct {
+	ResourceType string `yaml:"resourceType" json:"resourceType"`
+	ResourceID   string `yaml:"resourceId" json:"resourceId"`
+	ARN          string `yaml:"arn" json:"arn"`
+	AccountID    string `yaml:"accountId" json:"accountId"`
+	Region       string `yaml:"region" json:"region"`
+	// Tags all must exist on the resource, with matching values; an empty value matches any value
+	Tags map[string]string `yaml:"tags" json:"tags"`
+	// Expires date, as YYYY-MM-DD, after which the rule no longer matches; optional
+	Expires string `yaml:"expires" json:"expires"`
+	// Justification why the resources are left unmanaged
+	Justification string `yaml:"justification" json:"justification"`
+
+	expires  time.Time
+	matchers []fieldMatcher
+}
+
+// fieldMatcher match a single field of an item against a glob; field returns false
+// if the item does not have the field at all, e.g. a missing tag. Without a glob, the field
+// only has to exist.
+type fieldMatcher struct {
+	glob  string
+	re    *regexp.Regexp
+	field func(item *LocatedItem) (string, bool)
+}
+
+// LoadIgnoreRules load ignore rules from yaml or json.
+func LoadIgnoreRules(r io.Reader) ([]*IgnoreRule, error) {
+	var file IgnoreFile
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to decode ignore rules: %w", err)
+	}
+	var rules []*IgnoreRule
+	for i := range file.Ignore {
+		rule := file.Ignore[i]
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("invalid ignore rule %d: %w", i, err)
+		}
+		if rule.Expired(time.Now()) {
+			log.Warnf("ignore rule %d expired on %s, resources it matches will be reported as drift: %s", i, rule.Expires, rule.Justification)
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
+
+// compile parse the expiry date and compile the globs
+func (i *IgnoreRule) compile() error {
+	if i.Expires != "" {
+		expires, err := time.Parse(ignoreExpiresLayout, i.Expires)
+		if err != nil {
+			return fmt.Errorf("invalid expires %s, must be YYYY-MM-DD: %w", i.Expires, err)
+		}
+		// valid through the end of the day
+		i.expires = expires.AddDate(0, 0, 1)
+	}
+	fields := []fieldMatcher{
+		{glob: i.ResourceType, field: func(item *LocatedItem) (string, bool) { return item.ResourceType, true }},
+		{glob: i.ResourceID, field: func(item *LocatedItem) (string, bool) { return item.ResourceID, true }},
+		{glob: i.ARN, field: func(item *LocatedItem) (string, bool) { return item.ARN, true }},
+		{glob: i.AccountID, field: func(item *LocatedItem) (string, bool) { return item.AccountID, true }},
+		{glob: i.Region, field: func(item *LocatedItem) (string, bool) { return item.Region, true }},
+	}
+	for _, f := range fields {
+		if f.glob == "" {
+			continue
+		}
+		re, err := globToRegexp(f.glob)
+		if err != nil {
+			return err
+		}
+		f.re = re
+		i.matchers = append(i.matchers, f)
+	}
+	// a tag must exist even if its value is empty, which matches any value
+	for key, value := range i.Tags {
+		key := key
+		f := fieldMatcher{glob: value, field: func(item *LocatedItem) (string, bool) {
+			value, ok := item.Tags[key]
+			return value, ok
+		}}
+		if value != "" {
+			re, err := globToRegexp(value)
+			if err != nil {
+				return err
+			}
+			f.re = re
+		}
+		i.matchers = append(i.matchers, f)
+	}
+	if len(i.matchers) == 0 {
+		return errors.New("must have at least one matcher")
+	}
+	return nil
+}
+
+// Expired whether the rule has expired as of the given time.
+func (i *IgnoreRule) Expired(now time.Time) bool {
+	return !i.expires.IsZero() && !now.Before(i.expires)
+}
+
+// Match whether the rule matches the item as of the given time.
+func (i *IgnoreRule) Match(item *LocatedItem, now time.Time) bool {
+	if i.Expired(now) || len(i.matchers) == 0 {
+		return false
+	}
+	for _, m := range i.matchers {
+		value, ok := m.field(item)
+		if !ok || (m.re != nil && !m.re.MatchString(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// globToRegexp convert a glob, where * matches anything and ? matches a single character,
+// to an anchored regexp.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
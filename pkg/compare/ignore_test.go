@@ -0,0 +1,73 @@
+package compare
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	volume := &LocatedItem{ConfigurationItem: &load.ConfigurationItem{
+		ResourceType: "AWS::EC2::Volume",
+		ResourceID:   "vol-0123456789",
+		ARN:          "arn:aws:ec2:us-east-1:123456789012:volume/vol-0123456789",
+		AccountID:    "123456789012",
+		Region:       "us-east-1",
+		Tags:         map[string]string{"team": "data", "scratch": ""},
+	}}
+	day := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		rule string
+		now  time.Time
+		want bool
+	}{
+		{"type", "resourceType: AWS::EC2::Volume", day, true},
+		{"other type", "resourceType: AWS::EC2::Instance", day, false},
+		{"glob star", "resourceId: vol-*", day, true},
+		{"glob star crosses slash", "arn: 'arn:aws:ec2:*:volume/*'", day, true},
+		{"glob question mark", "resourceId: vol-012345678?", day, true},
+		{"glob is anchored", "resourceId: vol-0123", day, false},
+		{"glob quotes regexp", "resourceId: vol.0123456789", day, false},
+		{"every matcher", "{resourceType: AWS::EC2::Volume, region: eu-west-1}", day, false},
+		{"tag value", "tags: {team: data}", day, true},
+		{"tag value glob", "tags: {team: 'd*'}", day, true},
+		{"other tag value", "tags: {team: web}", day, false},
+		{"tag key alone", "tags: {team: ''}", day, true},
+		{"tag key alone with empty value", "tags: {scratch: ''}", day, true},
+		{"missing tag key", "tags: {owner: ''}", day, false},
+		{"before expiry", "{resourceType: AWS::EC2::Volume, expires: 2024-06-30}", day, true},
+		{"through end of expiry day", "{resourceType: AWS::EC2::Volume, expires: 2024-06-30}", day.Add(24*time.Hour - time.Nanosecond), true},
+		{"after expiry day", "{resourceType: AWS::EC2::Volume, expires: 2024-06-30}", day.Add(24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadIgnoreRules(strings.NewReader("ignore:\n- " + tt.rule + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules[0].Match(volume, tt.now); got != tt.want {
+				t.Errorf("Match() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIgnoreRulesInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"no matcher", "justification: nothing"},
+		{"invalid expires", "{resourceType: AWS::EC2::Volume, expires: 30/06/2024}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadIgnoreRules(strings.NewReader("ignore:\n- " + tt.rule + "\n")); err == nil {
+				t.Error("LoadIgnoreRules() returned no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package compare
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TerraformImport a terraform import block for a resource that is only in AWS Config.
+type TerraformImport struct {
+	Item *LocatedItem
+	// Type and Name the address of the resource to import to, e.g. aws_instance.web
+	Type string
+	Name string
+	// ID the import ID; empty if it cannot be determined, e.g. because the terraform type
+	// has a composite import ID that AWS Config does not have
+	ID string
+}
+
+// Address the address of the resource to import to
+func (t TerraformImport) Address() string {
+	return t.Type + "." + t.Name
+}
+
+// importID the import ID of an item for a terraform type, empty if it has none
+type importID func(item *LocatedItem) string
+
+// terraformImportIDs the import ID of each terraform type whose import ID is not the resource ID
+// in AWS Config
+var terraformImportIDs = map[string]importID{
+	"aws_accessanalyzer_analyzer":          importByName,
+	"aws_athena_workgroup":                 importByName,
+	"aws_autoscaling_group":                importByName,
+	"aws_cloudformation_stack":             importByName,
+	"aws_cloudtrail":                       importByName,
+	"aws_cloudwatch_composite_alarm":       importByName,
+	"aws_cloudwatch_event_bus":             importByName,
+	"aws_cloudwatch_event_rule":            importByName,
+	"aws_cloudwatch_metric_alarm":          importByName,
+	"aws_codedeploy_deployment_config":     importByName,
+	"aws_config_config_rule":               importByName,
+	"aws_config_configuration_recorder":    importByName,
+	"aws_config_conformance_pack":          importByName,
+	"aws_db_cluster_snapshot":              importByName,
+	"aws_db_event_subscription":            importByName,
+	"aws_db_instance":                      importByName,
+	"aws_db_subnet_group":                  importByName,
+	"aws_dms_certificate":                  importByName,
+	"aws_dynamodb_table":                   importByName,
+	"aws_ecr_repository":                   importByName,
+	"aws_ecs_cluster":                      importByName,
+	"aws_eks_cluster":                      importByName,
+	"aws_elastic_beanstalk_application":    importByName,
+	"aws_elasticache_cluster":              importByName,
+	"aws_elasticache_subnet_group":         importByName,
+	"aws_elb":                              importByName,
+	"aws_glue_crawler":                     importByName,
+	"aws_iam_group":                        importByName,
+	"aws_iam_instance_profile":             importByName,
+	"aws_iam_role":                         importByName,
+	"aws_iam_user":                         importByName,
+	"aws_keyspaces_keyspace":               importByName,
+	"aws_kinesis_stream":                   importByName,
+	"aws_lambda_function":                  importByName,
+	"aws_launch_configuration":             importByName,
+	"aws_rds_cluster":                      importByName,
+	"aws_rds_global_cluster":               importByName,
+	"aws_schemas_registry":                 importByName,
+	"aws_ssm_document":                     importByName,
+	"aws_ssm_parameter":                    importByName,
+	"aws_acm_certificate":                  importByARN,
+	"aws_ecs_task_definition":              importByARN,
+	"aws_iam_policy":                       importByARN,
+	"aws_iam_saml_provider":                importByARN,
+	"aws_kinesis_firehose_delivery_stream": importByARN,
+	"aws_kinesis_stream_consumer":          importByARN,
+	"aws_lb":                               importByARN,
+	"aws_lb_listener":                      importByARN,
+	"aws_lb_listener_rule":                 importByARN,
+	"aws_lb_target_group":                  importByARN,
+	"aws_msk_cluster":                      importByARN,
+	"aws_secretsmanager_secret":            importByARN,
+	"aws_sns_topic":                        importByARN,
+	"aws_sns_topic_policy":                 importByARN,
+	"aws_sns_topic_subscription":           importByARN,
+	"aws_sqs_queue":                        importBySQSQueueURL,
+	"aws_sqs_queue_policy":                 importBySQSQueueURL,
+	// composite import IDs, which AWS Config does not have
+	"aws_api_gateway_stage":                     importUnsupported,
+	"aws_autoscaling_policy":                    importUnsupported,
+	"aws_autoscaling_schedule":                  importUnsupported,
+	"aws_elastic_beanstalk_application_version": importUnsupported,
+	"aws_lambda_permission":                     importUnsupported,
+	"aws_network_acl_association":               importUnsupported,
+	"aws_network_acl_rule":                      importUnsupported,
+	"aws_route":                                 importUnsupported,
+	"aws_route53_record":                        importUnsupported,
+	"aws_route_table_association":               importUnsupported,
+	"aws_s3control_storage_lens_configuration":  importUnsupported,
+	"aws_vpn_connection_route":                  importUnsupported,
+	"aws_wafv2_ip_set":                          importUnsupported,
+	"aws_wafv2_rule_group":                      importUnsupported,
+	"aws_wafv2_web_acl":                         importUnsupported,
+}
+
+func importByID(item *LocatedItem) string {
+	return item.ResourceID
+}
+
+func importByName(item *LocatedItem) string {
+	return item.ResourceName
+}
+
+func importByARN(item *LocatedItem) string {
+	return item.ARN
+}
+
+// importBySQSQueueURL the URL of the queue, https://sqs.<region>.amazonaws.com/<account>/<name>,
+// from its ARN
+func importBySQSQueueURL(item *LocatedItem) string {
+	if strings.HasPrefix(item.ResourceID, "https://") {
+		return item.ResourceID
+	}
+	parts := strings.SplitN(item.ARN, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", parts[3], parts[4], parts[5])
+}
+
+func importUnsupported(item *LocatedItem) string {
+	return ""
+}
+
+// invalidNameChars characters that are not allowed in terraform resource names
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// terraformName a valid terraform resource name for the item: its name, Name tag or ID,
+// in lower case, with every sequence of other characters replaced by _.
+func terraformName(item *LocatedItem) string {
+	name := item.ResourceName
+	if name == "" {
+		name = item.Tags["Name"]
+	}
+	if name == "" {
+		name = item.ResourceID
+	}
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	switch {
+	case name == "":
+		return "resource"
+	case name[0] >= '0' && name[0] <= '9':
+		// names must start with a letter or underscore
+		return "r_" + name
+	}
+	return name
+}
+
+// TerraformImports the import blocks for every item of a mapped type that is only in AWS Config,
+// i.e. not owned, directly or via its parent, and not accepted. Names are unique within each
+// terraform type; where several items would have the same name, all but the first, in order of
+// resource ID and ARN, get a numeric suffix, e.g. web_2. Imports are sorted by address.
+func TerraformImports(items []*LocatedItem) []TerraformImport {
+	var candidates []*LocatedItem
+	for _, item := range items {
+		if !item.Source(SourceConfig) || item.Owned() || item.Accepted() {
+			continue
+		}
+		if _, ok := awsConfigToTerraformTypeMap[item.ResourceType]; !ok {
+			continue
+		}
+		candidates = append(candidates, item)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].ResourceType != candidates[j].ResourceType {
+			return candidates[i].ResourceType < candidates[j].ResourceType
+		}
+		if candidates[i].ResourceID != candidates[j].ResourceID {
+			return candidates[i].ResourceID < candidates[j].ResourceID
+		}
+		return candidates[i].ARN < candidates[j].ARN
+	})
+	var (
+		imports []TerraformImport
+		used    = make(map[string]bool)
+	)
+	for _, item := range candidates {
+		terraformType := awsConfigToTerraformTypeMap[item.ResourceType]
+		id, ok := terraformImportIDs[terraformType]
+		if !ok {
+			id = importByID
+		}
+		base := terraformName(item)
+		name := base
+		for i := 2; used[terraformType+"."+name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[terraformType+"."+name] = true
+		imports = append(imports, TerraformImport{
+			Item: item,
+			Type: terraformType,
+			Name: name,
+			ID:   id(item),
+		})
+	}
+	sort.SliceStable(imports, func(i, j int) bool {
+		return imports[i].Address() < imports[j].Address()
+	})
+	return imports
+}
@@ -0,0 +1,328 @@
+package compare
+
+import (
+	"errors"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+// Index lookup of LocatedItems by resource type and identifier.
+// It is passed to each OwnershipRule, so that it can find the owner of an item.
+// The Index passed to a rule is scoped to the account and region of the item
+// to which it is applied, so that identical IDs in other accounts or regions are
+// not found.
+type Index interface {
+	// Get find an item by resource type and ID. For items without an ID, the ARN is used as the ID.
+	Get(resourceType, id string) (*LocatedItem, bool)
+	// GetByName find an item by resource type and name.
+	GetByName(resourceType, name string) (*LocatedItem, bool)
+	// GetByARN find an item by resource type and ARN.
+	GetByARN(resourceType, arn string) (*LocatedItem, bool)
+	// Add add an item under the given ID, for its resource type, replacing any existing one
+	// in the same account and region. An item without an account or region is given those of the Index.
+	Add(id string, item *LocatedItem)
+}
+
+// IndexKey the kind of key under which a Store keeps items.
+type IndexKey string
+
+const (
+	// ByID items by resource ID, or ARN for items without an ID
+	ByID IndexKey = "id"
+	// ByName items by resource name
+	ByName IndexKey = "name"
+	// ByARN items by ARN
+	ByARN IndexKey = "arn"
+)
+
+// Store the storage of the index of a single call to Reconcile, which keeps items by resource type
+// and key, with at most one item in each account and region, i.e. Scope, for each. Items are stored
+// while they are still being reconciled, and are changed after they are stored, so until Release is
+// called, a store must return the same *LocatedItem that was put, and keep any changes made to it.
+//
+// The index only needs the lean record of each item: the fields of its ConfigurationItem other than
+// the configuration and supplementary configuration. The complete item, which is most of the size
+// of large snapshots, is given to SetDetail, and only read through Detail, so that a store may keep
+// it elsewhere than in memory.
+type Store interface {
+	// Put store the item under the key, for its resource type. If an item of the same type is already
+	// stored under the key in the same scope, it is replaced if replace is true, else kept.
+	Put(by IndexKey, key string, item *LocatedItem, replace bool) error
+	// Candidates the items of the resource type stored under the key, in every scope, in the order
+	// in which they were first stored.
+	Candidates(by IndexKey, resourceType, key string) ([]*LocatedItem, error)
+	// Each call fn with the item of every key stored ByID, in the order in which the keys were first
+	// stored, including those stored while it iterates; a key whose item is replaced keeps its place.
+	// Stops at the first error of fn, and returns it.
+	Each(fn func(item *LocatedItem) error) error
+	// SetDetail keep the complete configuration item of a stored item, replacing any previous one.
+	// The item's own ConfigurationItem may then be reduced to its lean record.
+	SetDetail(item *LocatedItem, detail *load.ConfigurationItem) error
+	// Detail the complete configuration item of a stored item, as given to SetDetail, or the item's
+	// own ConfigurationItem if none was given.
+	Detail(item *LocatedItem) (*load.ConfigurationItem, error)
+	// Release tell the store that Reconcile no longer refers to any of the items that it returned,
+	// other than through the parents of other items, so that it may write back the changes to them,
+	// and drop them from memory. Items returned after that may be other *LocatedItem than before
+	// for the same item. Reconcile calls it after each item that it reconciles, but not once it
+	// has started to list the items that it returns.
+	Release() error
+}
+
+// memoryStore in-memory Store. The keys of each map are resource types, using the AWS-Config
+// keys; the values are map[string][]*LocatedItem, keyed by id, name or arn respectively,
+// with one entry per account and region. Each item is its own detail.
+type memoryStore struct {
+	items map[string]map[string][]*LocatedItem
+	names map[string]map[string][]*LocatedItem
+	arns  map[string]map[string][]*LocatedItem
+	// order the keys stored ByID, in the order in which they were first stored
+	order []memoryKey
+}
+
+// memoryKey a key stored ByID, in the scope of its item
+type memoryKey struct {
+	resourceType, key string
+	scope             Scope
+}
+
+// NewMemoryStore a Store that keeps everything in memory, which is the default of Reconcile.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		items: make(map[string]map[string][]*LocatedItem),
+		names: make(map[string]map[string][]*LocatedItem),
+		arns:  make(map[string]map[string][]*LocatedItem),
+	}
+}
+
+func (m *memoryStore) keys(by IndexKey) map[string]map[string][]*LocatedItem {
+	switch by {
+	case ByName:
+		return m.names
+	case ByARN:
+		return m.arns
+	default:
+		return m.items
+	}
+}
+
+func (m *memoryStore) Put(by IndexKey, key string, item *LocatedItem, replace bool) error {
+	keys := m.keys(by)
+	if _, ok := keys[item.ResourceType]; !ok {
+		keys[item.ResourceType] = make(map[string][]*LocatedItem)
+	}
+	scope := item.Scope()
+	candidates := keys[item.ResourceType][key]
+	for i, candidate := range candidates {
+		if candidate.Scope() == scope {
+			if replace {
+				candidates[i] = item
+			}
+			return nil
+		}
+	}
+	keys[item.ResourceType][key] = append(candidates, item)
+	if by == ByID {
+		m.order = append(m.order, memoryKey{resourceType: item.ResourceType, key: key, scope: scope})
+	}
+	return nil
+}
+
+func (m *memoryStore) Candidates(by IndexKey, resourceType, key string) ([]*LocatedItem, error) {
+	return m.keys(by)[resourceType][key], nil
+}
+
+func (m *memoryStore) Each(fn func(item *LocatedItem) error) error {
+	// by position, as fn may store more
+	for i := 0; i < len(m.order); i++ {
+		k := m.order[i]
+		for _, item := range m.items[k.resourceType][k.key] {
+			if item.Scope() != k.scope {
+				continue
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) Release() error {
+	return nil
+}
+
+func (m *memoryStore) SetDetail(item *LocatedItem, detail *load.ConfigurationItem) error {
+	item.ConfigurationItem = detail
+	return nil
+}
+
+func (m *memoryStore) Detail(item *LocatedItem) (*load.ConfigurationItem, error) {
+	return item.ConfigurationItem, nil
+}
+
+// index the index of Reconcile over a Store. As neither the Index passed to rules nor the
+// reconciling can handle errors of the store, the first is kept, and returned by Reconcile.
+type index struct {
+	store Store
+	err   error
+}
+
+func newIndex(store Store) *index {
+	return &index{store: store}
+}
+
+// scoped get an Index that only finds items in the given scope
+func (x *index) scoped(scope Scope) Index {
+	return scopedIndex{x: x, scope: scope}
+}
+
+// find find the item of the type under the key in the given scope, preferring an exact match
+// of the scope over a compatible one. Items of AWS Config that record that the resource was
+// deleted are kept in the store, so that later items of the same resource can be compared with
+// them, but are never found.
+func (x *index) find(by IndexKey, resourceType, key string, scope Scope) (*LocatedItem, bool) {
+	candidates, err := x.store.Candidates(by, resourceType, key)
+	if err != nil {
+		x.fail(err)
+		return nil, false
+	}
+	var found *LocatedItem
+	for _, candidate := range candidates {
+		if candidate.Deleted() {
+			continue
+		}
+		candidateScope := candidate.Scope()
+		if candidateScope == scope {
+			return candidate, true
+		}
+		if found == nil && candidateScope.Matches(scope) {
+			found = candidate
+		}
+	}
+	return found, found != nil
+}
+
+// findExact find the item of the type by ID in exactly the given scope, whether or not it was deleted
+func (x *index) findExact(resourceType, id string, scope Scope) (*LocatedItem, bool) {
+	candidates, err := x.store.Candidates(ByID, resourceType, id)
+	if err != nil {
+		x.fail(err)
+		return nil, false
+	}
+	for _, candidate := range candidates {
+		if candidate.Scope() == scope {
+			return candidate, true
+		}
+	}
+	return nil, false
+}
+
+func (x *index) put(by IndexKey, key string, item *LocatedItem, replace bool) {
+	item.store = x.store
+	if err := x.store.Put(by, key, item, replace); err != nil {
+		x.fail(err)
+	}
+}
+
+func (x *index) fail(err error) {
+	if x.err == nil {
+		x.err = err
+	}
+}
+
+// addName add the item by name, unless an item with the same type, name and scope already exists.
+func (x *index) addName(name string, item *LocatedItem) {
+	x.put(ByName, name, item, false)
+}
+
+// addARN add the item by ARN, replacing any existing one.
+func (x *index) addARN(arn string, item *LocatedItem) {
+	x.put(ByARN, arn, item, true)
+}
+
+// setDetail keep the complete configuration item of the item in the store
+func (x *index) setDetail(item *LocatedItem, detail *load.ConfigurationItem) {
+	if err := x.store.SetDetail(item, detail); err != nil {
+		x.fail(err)
+	}
+}
+
+// detail the complete configuration item of the item, or its own if the store fails
+func (x *index) detail(item *LocatedItem) *load.ConfigurationItem {
+	detail, err := x.store.Detail(item)
+	if err != nil {
+		x.fail(err)
+		return item.ConfigurationItem
+	}
+	return detail
+}
+
+// errEachDone stops Store.Each once index.each has called fn with as many items as it was asked to
+var errEachDone = errors.New("done")
+
+// each call fn with the first n items added by ID, in order, releasing the store after each
+func (x *index) each(n int, fn func(item *LocatedItem)) {
+	var i int
+	err := x.store.Each(func(item *LocatedItem) error {
+		if i >= n {
+			return errEachDone
+		}
+		i++
+		fn(item)
+		x.release()
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEachDone) {
+		x.fail(err)
+	}
+}
+
+// all return every item added by ID, in order, which the store keeps from then on
+func (x *index) all() []*LocatedItem {
+	var items []*LocatedItem
+	if err := x.store.Each(func(item *LocatedItem) error {
+		items = append(items, item)
+		return nil
+	}); err != nil {
+		x.fail(err)
+	}
+	return items
+}
+
+// release tell the store that none of the items it returned are referred to any more
+func (x *index) release() {
+	if err := x.store.Release(); err != nil {
+		x.fail(err)
+	}
+}
+
+// scopedIndex Index over an index, which only finds items in its scope
+type scopedIndex struct {
+	x     *index
+	scope Scope
+}
+
+func (s scopedIndex) Get(resourceType, id string) (*LocatedItem, bool) {
+	return s.x.find(ByID, resourceType, id, s.scope)
+}
+
+func (s scopedIndex) GetByName(resourceType, name string) (*LocatedItem, bool) {
+	return s.x.find(ByName, resourceType, name, s.scope)
+}
+
+func (s scopedIndex) GetByARN(resourceType, arn string) (*LocatedItem, bool) {
+	return s.x.find(ByARN, resourceType, arn, s.scope)
+}
+
+func (s scopedIndex) Add(id string, item *LocatedItem) {
+	// items created by rules, e.g. placeholders for owners, are in the scope of the index
+	if item.AccountID == "" {
+		item.AccountID = s.scope.AccountID
+	}
+	if item.Region == "" {
+		item.Region = s.scope.Region
+	}
+	s.x.put(ByID, id, item, true)
+}
@@ -0,0 +1,141 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+// InventoryItem the serializable form of a LocatedItem, so that it can be stored while it is being
+// reconciled, and the results used again without reconciling. It has the lean record of the
+// configuration item, i.e. without its configuration and supplementary configuration, which are
+// kept apart as an InventoryDetail. Its parent is referred to by a reference, e.g. a position or a
+// handle, which is up to whoever converts it.
+type InventoryItem struct {
+	// Item the lean record of the configuration item
+	Item               load.ConfigurationItem       `json:"item"`
+	SourceFile         string                       `json:"sourceFile,omitempty"`
+	Sources            []string                     `json:"sources,omitempty"`
+	Parent             *int64                       `json:"parent,omitempty"`
+	OwnershipReason    string                       `json:"ownershipReason,omitempty"`
+	AcceptedBy         *IgnoreRule                  `json:"acceptedBy,omitempty"`
+	MappedType         bool                         `json:"mappedType"`
+	TerraformResources []InventoryTerraformResource `json:"terraformResources,omitempty"`
+	IaCResources       []IaCResource                `json:"iacResources,omitempty"`
+}
+
+// InventoryTerraformResource a TerraformResource with the attributes of the resource instance.
+type InventoryTerraformResource struct {
+	TerraformResource
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// InventoryDetail the serializable form of a complete configuration item, as returned by
+// LocatedItem.Detail, which keeps its raw configuration and supplementary configuration.
+type InventoryDetail struct {
+	// Item the configuration item; its configuration and supplementary configuration are only the
+	// typed fields if the raw ones are not kept
+	Item                       load.ConfigurationItem `json:"item"`
+	Configuration              json.RawMessage        `json:"configuration,omitempty"`
+	SupplementaryConfiguration json.RawMessage        `json:"supplementaryConfiguration,omitempty"`
+}
+
+// ToInventoryItem convert the item to an InventoryItem, referring to its parent, if any, by what
+// ref returns for it. The parent of an item restored by FromInventoryItem that was not resolved
+// keeps its reference.
+func ToInventoryItem(l *LocatedItem, ref func(parent *LocatedItem) (int64, error)) (InventoryItem, error) {
+	item := InventoryItem{
+		Item:            *l.ConfigurationItem.Lean(),
+		SourceFile:      l.SourceFile,
+		Sources:         l.Sources(),
+		OwnershipReason: l.ownershipReason,
+		AcceptedBy:      l.acceptedBy,
+		MappedType:      l.mappedType,
+		IaCResources:    l.iacResources,
+	}
+	switch {
+	case l.parent != nil:
+		parent, err := ref(l.parent)
+		if err != nil {
+			return item, err
+		}
+		item.Parent = &parent
+	case l.storedParent != nil:
+		parent := l.storedParent.ref
+		item.Parent = &parent
+	}
+	for _, resource := range l.terraformResources {
+		item.TerraformResources = append(item.TerraformResources, InventoryTerraformResource{
+			TerraformResource: resource,
+			Attributes:        resource.attributes,
+		})
+	}
+	return item, nil
+}
+
+// FromInventoryItem restore an item converted by ToInventoryItem, whose complete configuration item
+// is kept by the store. Its parent is resolved by resolve when it is first needed, so that restoring
+// an item does not restore every item above it.
+func FromInventoryItem(stored InventoryItem, store Store, resolve func(ref int64) *LocatedItem) *LocatedItem {
+	ci := stored.Item
+	ci.SourceFile = stored.SourceFile
+	// decoding the empty configuration keeps it as if it were raw
+	ci.Configuration = load.Configuration{}
+	ci.SupplementaryConfiguration = load.SupplementaryConfiguration{}
+	item := &LocatedItem{
+		ConfigurationItem: &ci,
+		ownershipReason:   stored.OwnershipReason,
+		acceptedBy:        stored.AcceptedBy,
+		mappedType:        stored.MappedType,
+		iacResources:      stored.IaCResources,
+		store:             store,
+	}
+	for _, source := range stored.Sources {
+		item.addSource(source)
+	}
+	for _, resource := range stored.TerraformResources {
+		resource.TerraformResource.attributes = resource.Attributes
+		item.terraformResources = append(item.terraformResources, resource.TerraformResource)
+	}
+	if stored.Parent != nil {
+		item.storedParent = &storedParent{ref: *stored.Parent, resolve: resolve}
+	}
+	return item
+}
+
+// ToInventoryDetail convert the complete configuration item to an InventoryDetail.
+func ToInventoryDetail(ci *load.ConfigurationItem) InventoryDetail {
+	detail := InventoryDetail{Item: *ci}
+	// the raw configuration is all of the typed fields, so they need not be kept twice
+	if raw := ci.Configuration.Raw; len(raw) > 0 {
+		detail.Configuration = raw
+		detail.Item.Configuration = load.Configuration{}
+	}
+	if raw := ci.SupplementaryConfiguration.Raw; len(raw) > 0 {
+		detail.SupplementaryConfiguration = raw
+		detail.Item.SupplementaryConfiguration = load.SupplementaryConfiguration{}
+	}
+	return detail
+}
+
+// FromInventoryDetail restore a configuration item converted by ToInventoryDetail.
+func FromInventoryDetail(detail InventoryDetail) (*load.ConfigurationItem, error) {
+	ci := detail.Item
+	if len(detail.Configuration) > 0 {
+		if err := json.Unmarshal(detail.Configuration, &ci.Configuration); err != nil {
+			return nil, fmt.Errorf("invalid configuration of %s %s: %w", ci.ResourceType, ci.ResourceID, err)
+		}
+	} else {
+		// decoding the typed fields keeps them as if they were raw, which they are not
+		ci.Configuration.Raw = nil
+	}
+	if len(detail.SupplementaryConfiguration) > 0 {
+		if err := json.Unmarshal(detail.SupplementaryConfiguration, &ci.SupplementaryConfiguration); err != nil {
+			return nil, fmt.Errorf("invalid supplementary configuration of %s %s: %w", ci.ResourceType, ci.ResourceID, err)
+		}
+	} else {
+		ci.SupplementaryConfiguration.Raw = nil
+	}
+	return &ci, nil
+}
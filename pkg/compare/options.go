@@ -0,0 +1,125 @@
+package compare
+
+import "github.com/iac-reconciler/aws-config/pkg/load"
+
+// Option configures a single call to Reconcile.
+type Option func(*options)
+
+type options struct {
+	disabledRules   map[string]bool
+	rules           []OwnershipRule
+	ignoreRules     []*IgnoreRule
+	terraformScopes map[string]Scope
+	providers       []string
+	stats           *Stats
+	iacResources    []IaCResource
+	store           Store
+	// skippedProviders resources of IaC other than terraform that were skipped when converted
+	skippedProviders map[string]int
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		disabledRules:    make(map[string]bool),
+		terraformScopes:  make(map[string]Scope),
+		providers:        DefaultProviderPatterns,
+		skippedProviders: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithDisabledOwnershipRules do not apply the ownership rules with the given names.
+func WithDisabledOwnershipRules(names ...string) Option {
+	return func(o *options) {
+		for _, name := range names {
+			o.disabledRules[name] = true
+		}
+	}
+}
+
+// WithOwnershipRules apply the given rules in addition to the registered ones,
+// after all of the registered ones.
+func WithOwnershipRules(rules ...OwnershipRule) Option {
+	return func(o *options) {
+		o.rules = append(o.rules, rules...)
+	}
+}
+
+// WithIgnoreRules mark items that match any of the rules as accepted, i.e. deliberately unmanaged.
+func WithIgnoreRules(rules ...*IgnoreRule) Option {
+	return func(o *options) {
+		o.ignoreRules = append(o.ignoreRules, rules...)
+	}
+}
+
+// WithTerraformScope set the account and region of the resources in a terraform statefile,
+// for those whose ARN does not include them. By default, they are inferred from the most
+// common account and region among the ARNs in the statefile.
+func WithTerraformScope(statefile string, scope Scope) Option {
+	return func(o *options) {
+		o.terraformScopes[statefile] = scope
+	}
+}
+
+// WithProviderPatterns only reconcile terraform resources whose provider source address, as returned
+// by ProviderSource, matches one of the patterns, replacing DefaultProviderPatterns. Each pattern is
+// a glob, e.g. registry.example.com/*/aws.
+func WithProviderPatterns(patterns ...string) Option {
+	return func(o *options) {
+		o.providers = patterns
+	}
+}
+
+// WithStats fill in stats with the statistics of the Reconcile.
+func WithStats(stats *Stats) Option {
+	return func(o *options) {
+		o.stats = stats
+	}
+}
+
+// WithIaCResources reconcile resources of IaC other than terraform, each under its own source.
+func WithIaCResources(resources ...IaCResource) Option {
+	return func(o *options) {
+		o.iacResources = append(o.iacResources, resources...)
+	}
+}
+
+// WithCloudFormationStackResources reconcile the resources of CloudFormation stacks, under SourceCloudFormation.
+func WithCloudFormationStackResources(resources ...load.StackResource) Option {
+	return WithIaCResources(cloudFormationResources(resources)...)
+}
+
+// WithPulumiResources reconcile the resources of pulumi stacks, under SourcePulumi. Resources of
+// packages other than aws and aws-native are counted in Stats.SkippedProviders.
+func WithPulumiResources(resources ...load.PulumiResource) Option {
+	converted, skipped := pulumiResources(resources)
+	return func(o *options) {
+		o.iacResources = append(o.iacResources, converted...)
+		for provider, count := range skipped {
+			o.skippedProviders[provider] += count
+		}
+	}
+}
+
+// WithCrossplaneResources reconcile crossplane managed resources, under SourceCrossplane. Resources of
+// providers other than AWS are counted in Stats.SkippedProviders.
+func WithCrossplaneResources(resources ...load.CrossplaneResource) Option {
+	converted, skipped := crossplaneResources(resources)
+	return func(o *options) {
+		o.iacResources = append(o.iacResources, converted...)
+		for provider, count := range skipped {
+			o.skippedProviders[provider] += count
+		}
+	}
+}
+
+// WithStore keep the index of items in the given store, rather than in memory, e.g. one that is
+// on disk. The store must be empty.
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
@@ -0,0 +1,78 @@
+package compare
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	terraformDefaultRegistry  = "registry.terraform.io"
+	terraformDefaultNamespace = "hashicorp"
+	legacyProviderPrefix      = "provider."
+	providerPrefix            = `provider["`
+)
+
+// DefaultProviderPatterns the source addresses of the providers whose resources are reconciled,
+// unless overridden: the AWS provider from the terraform and OpenTofu registries. Aliased
+// providers, and providers configured in modules, match the pattern of their source address.
+var DefaultProviderPatterns = []string{
+	"registry.terraform.io/hashicorp/aws",
+	"registry.opentofu.org/hashicorp/aws",
+	"registry.opentofu.org/opentofu/aws",
+}
+
+// ProviderSource the source address of the provider of a terraform resource, e.g.
+// registry.terraform.io/hashicorp/aws, from the provider in the state, in any of its forms:
+//
+//	provider["registry.terraform.io/hashicorp/aws"]
+//	provider["registry.terraform.io/hashicorp/aws"].west
+//	module.vpc.provider["registry.opentofu.org/hashicorp/aws"]
+//	provider.aws
+//	module.vpc.provider.aws.west
+//
+// Legacy providers, which only have a type, are assumed to be from the default namespace of
+// the terraform registry. Returns the provider unchanged if it is in none of these forms.
+func ProviderSource(provider string) string {
+	// strip the module, if any
+	if i := strings.LastIndex(provider, providerPrefix); i >= 0 {
+		source, _, ok := strings.Cut(provider[i+len(providerPrefix):], `"]`)
+		if !ok {
+			return provider
+		}
+		return source
+	}
+	if i := strings.LastIndex(provider, legacyProviderPrefix); i >= 0 && (i == 0 || provider[i-1] == '.') {
+		// strip the alias, if any
+		providerType, _, _ := strings.Cut(provider[i+len(legacyProviderPrefix):], ".")
+		return terraformDefaultRegistry + "/" + terraformDefaultNamespace + "/" + providerType
+	}
+	return provider
+}
+
+// providerMatcher matches provider source addresses against glob patterns
+type providerMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+func newProviderMatcher(patterns []string) (*providerMatcher, error) {
+	m := &providerMatcher{}
+	for _, pattern := range patterns {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider pattern %s: %w", pattern, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+	return m, nil
+}
+
+// match whether the source address matches any of the patterns
+func (m *providerMatcher) match(source string) bool {
+	for _, re := range m.patterns {
+		if re.MatchString(source) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package compare
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// pulumiAWSPackage the package of the AWS provider, whose types are bridged from terraform
+	pulumiAWSPackage = "aws"
+	// pulumiAWSNativePackage the package of the AWS Cloud Control provider, whose types
+	// follow the CloudFormation types
+	pulumiAWSNativePackage = "aws-native"
+	// pulumiProviderPrefix the type prefix of provider resources, e.g. pulumi:providers:aws
+	pulumiProviderPrefix = "pulumi:providers:"
+	// pulumiInternalPrefix the type prefix of pulumi's own resources, e.g. the stack
+	pulumiInternalPrefix = "pulumi:"
+)
+
+// awsPulumiToConfigTypeMap maps types from pulumi to config types
+//
+//go:embed pulumi_typemap.json
+var awsPulumiToConfigTypeMapJSON []byte
+
+var awsPulumiToConfigTypeMap map[string]string
+
+func init() {
+	awsPulumiToConfigTypeMap = make(map[string]string)
+	if err := json.Unmarshal(awsPulumiToConfigTypeMapJSON, &awsPulumiToConfigTypeMap); err != nil {
+		log.Fatalf("unable to unmarshal pulumi_typemap.json: %v", err)
+	}
+}
+
+// pulumiConfigType the AWS Config type of a pulumi type, and whether it is mapped. aws types are
+// mapped by pulumi_typemap.json; aws-native types, e.g. aws-native:ec2:Instance, are the
+// CloudFormation type, which is the Config type, in lower case.
+func pulumiConfigType(pulumiType string) (string, bool) {
+	if configType, ok := awsPulumiToConfigTypeMap[pulumiType]; ok {
+		return configType, true
+	}
+	parts := strings.Split(pulumiType, ":")
+	if len(parts) == 3 && parts[0] == pulumiAWSNativePackage {
+		if configType, ok := knownConfigType("AWS::" + parts[1] + "::" + parts[2]); ok {
+			return configType, true
+		}
+	}
+	return pulumiType, false
+}
+
+// pulumiResources convert the resources of pulumi stacks to IaCResources. Only managed resources
+// of the aws and aws-native packages are converted; the number of resources of other packages is
+// returned by package, e.g. pulumi:providers:kubernetes.
+func pulumiResources(resources []load.PulumiResource) ([]IaCResource, map[string]int) {
+	var (
+		converted       []IaCResource
+		skipped         = make(map[string]int)
+		providerRegions = make(map[string]string)
+	)
+	// the region of each provider, for resources whose ARN does not include it
+	for _, r := range resources {
+		if strings.HasPrefix(r.Type, pulumiProviderPrefix) {
+			region := stringValue(r.Outputs, "region")
+			if region == "" {
+				region = stringValue(r.Inputs, "region")
+			}
+			providerRegions[r.URN+"::"+r.ID] = region
+		}
+	}
+	for _, r := range resources {
+		if !r.Custom || r.Delete || r.External || strings.HasPrefix(r.Type, pulumiInternalPrefix) {
+			continue
+		}
+		pkg, _, _ := strings.Cut(r.Type, ":")
+		if pkg != pulumiAWSPackage && pkg != pulumiAWSNativePackage {
+			skipped[pulumiProviderPrefix+pkg]++
+			continue
+		}
+		configType, mapped := pulumiConfigType(r.Type)
+		resource := IaCResource{
+			Source:       SourcePulumi,
+			ResourceType: configType,
+			ResourceID:   r.ID,
+			ARN:          stringValue(r.Outputs, "arn"),
+			Name:         stringValue(r.Outputs, "name"),
+			MappedType:   mapped,
+			Origin:       r.URN,
+		}
+		resource.Scope = ScopeFromARN(resource.ARN)
+		if resource.Scope.Region == "" {
+			resource.Scope.Region = providerRegions[r.Provider]
+		}
+		converted = append(converted, resource)
+	}
+	return converted, skipped
+}
+
+// stringValue the value of the key in m if it is a string, e.g. not a secret
+func stringValue(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
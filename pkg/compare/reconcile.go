@@ -2,632 +2,394 @@ package compare
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/iac-reconciler/aws-config/pkg/load"
 	log "github.com/sirupsen/logrus"
 )
 
-// LocatedItem is a configuration item that has been located in a source.
-// That source could be a snapshot, a terraform state, or both.
-// It also includes a parent, if any.
+// LocatedItem is a configuration item that has been located in one or more sources.
+// Those sources could be a snapshot, a terraform state, other IaC, or any combination.
+// It also includes a parent, if any. The ConfigurationItem may be only the lean record of
+// the item, if its Store keeps the rest elsewhere; Detail returns all of it.
 type LocatedItem struct {
 	*load.ConfigurationItem
-	config     bool
-	terraform  bool
-	parent     *LocatedItem
-	mappedType bool // indicates if the type was mapped between sources, or unique
+	sources         []string // names of the sources in which it was found, e.g. SourceConfig
+	parent          *LocatedItem
+	ownershipReason string      // why the parent is considered to own the item
+	acceptedBy      *IgnoreRule // rule by which the item is accepted as unmanaged, if any
+	mappedType      bool        // indicates if the type was mapped between sources, or unique
+	// terraformResources the terraform resource instances that matched the item
+	terraformResources []TerraformResource
+	// iacResources the resources of IaC other than terraform that matched the item
+	iacResources []IaCResource
+	// store the store that keeps the complete configuration item, if any
+	store Store
+	// storedParent the parent of an item restored from a store, which is only resolved when needed
+	storedParent *storedParent
 }
 
+// storedParent a reference to the parent of an item restored by FromInventoryItem
+type storedParent struct {
+	ref     int64
+	resolve func(ref int64) *LocatedItem
+}
+
+// Source indicates if the item was found in the named source, e.g. SourceConfig,
+// or, for "owned", if it is owned.
 func (l LocatedItem) Source(src string) bool {
-	switch strings.ToLower(src) {
-	case "config":
-		return l.config
-	case "terraform":
-		return l.terraform
-	case "owned":
+	src = strings.ToLower(src)
+	if src == "owned" {
 		return l.Owned()
-	default:
-		return false
 	}
+	for _, found := range l.sources {
+		if found == src {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources returns the names of the sources in which the item was found, sorted.
+func (l LocatedItem) Sources() []string {
+	var sources []string
+	for _, key := range SourceKeys {
+		if l.Source(key) {
+			sources = append(sources, key)
+		}
+	}
+	return sources
+}
+
+// addSource mark the item as found in the named source
+func (l *LocatedItem) addSource(src string) {
+	if !l.Source(src) {
+		l.sources = append(l.sources, src)
+	}
+}
+
+// Detail returns the complete configuration item, including its configuration and supplementary
+// configuration, which the embedded ConfigurationItem may not have if its store keeps them elsewhere.
+func (l *LocatedItem) Detail() (*load.ConfigurationItem, error) {
+	if l.store == nil {
+		return l.ConfigurationItem, nil
+	}
+	return l.store.Detail(l)
+}
+
+// Lookup the values at the path in the complete configuration item, as load.ConfigurationItem.Lookup.
+func (l *LocatedItem) Lookup(p load.Path) ([]interface{}, error) {
+	detail, err := l.Detail()
+	if err != nil {
+		return nil, err
+	}
+	return detail.Lookup(p)
+}
+
+// LookupString the values at the path in the complete configuration item, as
+// load.ConfigurationItem.LookupString.
+func (l *LocatedItem) LookupString(p load.Path) ([]string, error) {
+	detail, err := l.Detail()
+	if err != nil {
+		return nil, err
+	}
+	return detail.LookupString(p)
+}
+
+// IaC indicates if the item was found in any IaC source, i.e. any source other than SourceConfig.
+func (l LocatedItem) IaC() bool {
+	for _, src := range l.sources {
+		if src != SourceConfig {
+			return true
+		}
+	}
+	return false
 }
 
 func (l LocatedItem) Owned() bool {
-	return l.terraform || l.parent != nil
+	return l.IaC() || l.parent != nil || l.storedParent != nil
 }
 
 func (l LocatedItem) Ephemeral() bool {
-	return !l.terraform && !l.config
+	return len(l.sources) == 0
 }
 
-// Reconcile reconcile the snapshot and tfstates.
-func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState) (items []*LocatedItem, err error) {
-	// the keys are resource types, using the AWS-Config keys;
-	// the values are map[string]*LocatedItem
-	// in there, the keys are arn or id (if no arn), the values are
-	// the LocatedItem, which includes marking where it was seen.
-	var (
-		itemToLocation = make(map[string]map[string]*LocatedItem)
-		nameToLocation = make(map[string]map[string]*LocatedItem)
-		arnToLocation  = make(map[string]map[string]*LocatedItem)
+// Parent returns the immediate owner of the item, or nil if it has none.
+func (l LocatedItem) Parent() *LocatedItem {
+	if l.parent == nil && l.storedParent != nil {
+		return l.storedParent.resolve(l.storedParent.ref)
+	}
+	return l.parent
+}
+
+// OwnershipReason returns why the parent is considered to own the item, e.g.
+// OwnershipReasonStackContains. Empty if the item has no parent.
+func (l LocatedItem) OwnershipReason() string {
+	return l.ownershipReason
+}
+
+// setParent set the owner of the item, and the reason it is considered the owner
+func (l *LocatedItem) setParent(parent *LocatedItem, reason string) {
+	l.parent = parent
+	l.storedParent = nil
+	l.ownershipReason = reason
+}
+
+// Accepted indicates if the item matched an IgnoreRule, i.e. it is deliberately unmanaged.
+func (l LocatedItem) Accepted() bool {
+	return l.acceptedBy != nil
+}
+
+// AcceptedBy returns the IgnoreRule that the item matched, or nil if none.
+func (l LocatedItem) AcceptedBy() *IgnoreRule {
+	return l.acceptedBy
+}
 
-		uuidSize = len(uuid.New().String())
+// Scope returns the account and region of the item, either or both of which may be unknown.
+func (l LocatedItem) Scope() Scope {
+	return Scope{AccountID: l.AccountID, Region: l.Region}
+}
+
+// MappedType indicates if the resource type is mapped between AWS Config and terraform.
+func (l LocatedItem) MappedType() bool {
+	return l.mappedType
+}
+
+// Parents returns the chain of owners of the item, starting with the immediate
+// parent and ending with the root owner. Returns nil if the item has no parent.
+func (l LocatedItem) Parents() []*LocatedItem {
+	var (
+		parents []*LocatedItem
+		seen    = make(map[*LocatedItem]bool)
 	)
+	for parent := l.Parent(); parent != nil && !seen[parent]; parent = parent.Parent() {
+		seen[parent] = true
+		parents = append(parents, parent)
+	}
+	return parents
+}
 
-	// we will do this in 2 passes. The first pass is to get the raw resources as they are
-	// the second pass is to find those resources that contain other resources
-	for _, item := range snapshot.ConfigurationItems {
-		item := item // otherwise the pointer goes back to the original
-		if item.ResourceType == configComplianceResourceType {
-			continue
-		}
-		if item.ResourceType == "" {
-			log.Warnf("AWS Config snapshot: empty resource type for item %s", item.ARN)
+// Statefiles returns the terraform statefiles in which the item was found.
+func (l LocatedItem) Statefiles() []string {
+	var (
+		statefiles []string
+		seen       = make(map[string]bool)
+	)
+	for _, resource := range l.terraformResources {
+		if seen[resource.Statefile] {
 			continue
 		}
+		seen[resource.Statefile] = true
+		statefiles = append(statefiles, resource.Statefile)
+	}
+	return statefiles
+}
 
-		var mappedType = true
-		if _, ok := awsConfigToTerraformTypeMap[item.ResourceType]; !ok {
-			mappedType = false
-		}
-		if _, ok := itemToLocation[item.ResourceType]; !ok {
-			itemToLocation[item.ResourceType] = make(map[string]*LocatedItem)
+// Duplicate indicates if the item is managed by more than one terraform resource
+// instance, whether in the same or in different statefiles.
+func (l LocatedItem) Duplicate() bool {
+	return len(l.terraformResources) > 1
+}
+
+// TerraformResources returns the terraform resource instances that matched the item.
+func (l LocatedItem) TerraformResources() []TerraformResource {
+	return l.terraformResources
+}
+
+// ItemSource the configuration items to reconcile, which calls fn with each in turn, e.g. as they
+// are decoded by load.StreamSnapshots, so that they need not all be held at once. It stops at the
+// first error of fn, and returns it.
+type ItemSource func(fn func(item *load.ConfigurationItem) error) error
+
+// Reconcile reconcile the snapshot and tfstates.
+func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState, opts ...Option) ([]*LocatedItem, error) {
+	return ReconcileItems(func(fn func(item *load.ConfigurationItem) error) error {
+		// the items are not copied, so that the index refers to the items as they were decoded
+		for i := range snapshot.ConfigurationItems {
+			if err := fn(&snapshot.ConfigurationItems[i]); err != nil {
+				return err
+			}
 		}
-		if _, ok := nameToLocation[item.ResourceType]; !ok {
-			nameToLocation[item.ResourceType] = make(map[string]*LocatedItem)
+		return nil
+	}, tfstates, opts...)
+}
+
+// ReconcileItems reconcile the configuration items of the source and tfstates. Each item is indexed
+// as it is read, keeping only the latest of each resource, by capture time, where the snapshots have
+// more than one, e.g. from several deliveries. Resources whose latest item records that they were
+// deleted are dropped, and counted in stats.
+func ReconcileItems(source ItemSource, tfstates map[string]load.TerraformState, opts ...Option) (items []*LocatedItem, err error) {
+	o := newOptions(opts)
+	store := o.store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	idx := newIndex(store)
+	engine, err := newRuleEngine(append(OwnershipRules(), o.rules...), o.disabledRules)
+	if err != nil {
+		return nil, err
+	}
+	providers, err := newProviderMatcher(o.providers)
+	if err != nil {
+		return nil, err
+	}
+	stats := o.stats
+	if stats == nil {
+		stats = &Stats{}
+	}
+	stats.SkippedProviders = make(map[string]int)
+	stats.DeletedResources = 0
+	for provider, count := range o.skippedProviders {
+		stats.SkippedProviders[provider] += count
+	}
+
+	// index the items by ID as they are read, replacing each with any later one of the same resource;
+	// those that were deleted are kept until then, as a later one may be older
+	var configured int
+	err = source(func(item *load.ConfigurationItem) error {
+		if item.ResourceType == configComplianceResourceType {
+			return nil
 		}
-		if _, ok := arnToLocation[item.ResourceType]; !ok {
-			arnToLocation[item.ResourceType] = make(map[string]*LocatedItem)
+		if item.ResourceType == "" {
+			log.Warnf("AWS Config snapshot: empty resource type for item %s", item.ARN)
+			return nil
 		}
 		key := item.ResourceID
 		if key == "" {
 			key = item.ARN
 		}
-		var (
-			detail *LocatedItem
-			ok     bool
-		)
-		if detail, ok = itemToLocation[item.ResourceType][key]; !ok {
-			detail = &LocatedItem{
-				ConfigurationItem: &item,
-				mappedType:        mappedType,
+		// identical IDs in different accounts or regions are different items
+		if existing, ok := idx.findExact(item.ResourceType, key, Scope{AccountID: item.AccountID, Region: item.Region}); ok {
+			if item.CaptureTime.After(existing.CaptureTime.Time) {
+				idx.setDetail(existing, item)
 			}
-			itemToLocation[item.ResourceType][key] = detail
+			idx.release()
+			return nil
 		}
-		if item.ARN != "" {
-			arnToLocation[item.ResourceType][item.ARN] = detail
+		_, mappedType := awsConfigToTerraformTypeMap[item.ResourceType]
+		located := &LocatedItem{
+			ConfigurationItem: item,
+			mappedType:        mappedType,
+			sources:           []string{SourceConfig},
+		}
+		// the detail first, so that a store can keep it with the item as it stores it
+		idx.setDetail(located, item)
+		idx.put(ByID, key, located, true)
+		configured++
+		idx.release()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// we will do this in 3 passes over the items of AWS Config, which are the first ones indexed.
+	// The first pass is to index the rest of the resources as they are
+	// the second pass is to find those resources that contain other resources
+	idx.each(configured, func(located *LocatedItem) {
+		if located.Deleted() {
+			log.Debugf("AWS Config snapshot: %s %s was deleted at %s", located.ResourceType, located.ResourceID, located.CaptureTime.Format(time.RFC3339))
+			stats.DeletedResources++
+			return
+		}
+		if located.ARN != "" {
+			idx.addARN(located.ARN, located)
 		}
 		// we also map by name, if it exists, knowing it is a duplicate;
 		// this is needed because the cloudformation and elasticbeanstalk stacks
 		// sometimes reference a name, even though they call it an ID
-		if _, ok := nameToLocation[item.ResourceType][item.ResourceName]; !ok {
-			nameToLocation[item.ResourceType][item.ResourceName] = detail
-		}
-		detail.config = true
+		idx.addName(located.ResourceName, located)
 
 		// handle special resources that have children
 
 		// routetable associations
-		if item.ResourceType == resourceTypeRouteTable {
+		if located.ResourceType == resourceTypeRouteTable {
 			// we will just create resources for these associations, as that is how AWSConfig
 			// (sort of) sees it
-			subType := resourceTypeRouteTableAssociation
-			if _, ok := itemToLocation[subType]; !ok {
-				itemToLocation[subType] = make(map[string]*LocatedItem)
-			}
-			if _, ok := nameToLocation[subType]; !ok {
-				nameToLocation[subType] = make(map[string]*LocatedItem)
-			}
-			for _, assoc := range item.Configuration.Associations {
-				itemToLocation[subType][assoc.AssociationID] = &LocatedItem{
+			scoped := idx.scoped(located.Scope())
+			for _, assoc := range idx.detail(located).Configuration.Associations {
+				scoped.Add(assoc.AssociationID, &LocatedItem{
 					ConfigurationItem: &load.ConfigurationItem{
-						ResourceType: subType,
+						ResourceType: resourceTypeRouteTableAssociation,
 						ResourceID:   assoc.AssociationID,
+						AccountID:    located.AccountID,
+						Region:       located.Region,
 					},
 					mappedType: true,
-					config:     true,
-				}
+					sources:    []string{SourceConfig},
+				})
 			}
 		}
-	}
+	})
 
 	// second pass for CloudFormation-owned resources
-	for _, item := range snapshot.ConfigurationItems {
-		// get the correct LocatedItem pointer for this item
-		var (
-			located *LocatedItem
-			ok      bool
-		)
-		key := item.ResourceID
-		if key == "" {
-			key = item.ARN
-		}
-		if located, ok = itemToLocation[item.ResourceType][key]; !ok {
-			log.Warnf("found unknown resource: %s %s", item.ResourceType, key)
-			continue
-		}
-
+	idx.each(configured, func(located *LocatedItem) {
 		// CloudFormation and Beanstalk created items
-		if item.ResourceType == resourceTypeStack || item.ResourceType == resourceTypeElasticBeanstalk {
-			// track subsidiary resources
-			for _, resource := range item.Relationships {
-				if resource.ResourceType == "" {
-					log.Warnf("AWS Config snapshot: empty resource type for item %s", resource.ResourceID)
-					continue
-				}
-				// only care about those contained
-				if strings.TrimSpace(resource.Name) != resourceContains {
-					continue
-				}
-				if _, ok := itemToLocation[resource.ResourceType]; !ok {
-					itemToLocation[resource.ResourceType] = make(map[string]*LocatedItem)
-				}
-				var (
-					detail *LocatedItem
-					ok     bool
-				)
-				key := resource.ResourceID
-				if key == "" {
-					key = resource.ResourceName
-				}
-				if detail, ok = itemToLocation[resource.ResourceType][key]; !ok {
-					// try by name
-					if detail, ok = nameToLocation[resource.ResourceType][key]; !ok {
-						detail = &LocatedItem{
-							ConfigurationItem: &load.ConfigurationItem{
-								ResourceType: resource.ResourceType,
-								ResourceID:   resource.ResourceID,
-							},
-						}
-						itemToLocation[resource.ResourceType][resource.ResourceID] = detail
-					}
-				}
-				detail.parent = located
-			}
-
-			for _, resource := range item.SupplementaryConfiguration.UnsupportedResources {
-				if resource.ResourceType == "" {
-					log.Warnf("AWS Config snapshot: empty resource type for item %s", resource.ResourceID)
-					continue
-				}
-				if resource.ResourceID == "" {
-					log.Warnf("AWS Config snapshot: empty resource ID for item %s", resource.ResourceType)
-					continue
-				}
-				if _, ok := itemToLocation[resource.ResourceType]; !ok {
-					itemToLocation[resource.ResourceType] = make(map[string]*LocatedItem)
-				}
-				var (
-					detail *LocatedItem
-					ok     bool
-				)
-				key := resource.ResourceID
-				if detail, ok = itemToLocation[resource.ResourceType][key]; !ok {
-					// try by name
-					if detail, ok = nameToLocation[resource.ResourceType][key]; !ok {
-						detail = &LocatedItem{
-							ConfigurationItem: &load.ConfigurationItem{
-								ResourceType: resource.ResourceType,
-								ResourceID:   resource.ResourceID,
-							},
-						}
-						itemToLocation[resource.ResourceType][resource.ResourceID] = detail
-					}
-				}
-				detail.parent = located
-			}
-		}
-	}
-
-	// third pass just for resources that contain others
-	for _, item := range snapshot.ConfigurationItems {
-		// get the correct LocatedItem pointer for this item
-		var (
-			located *LocatedItem
-			ok      bool
-		)
-		key := item.ResourceID
-		if key == "" {
-			key = item.ARN
-		}
-		if located, ok = itemToLocation[item.ResourceType][key]; !ok {
-			log.Warnf("found unknown resource: %s %s", item.ResourceType, key)
-			continue
-		}
-
-		// EKS-created ENIs
-		if item.ResourceType == resourceTypeENI {
-			// handle RDS instance-owned ENIs; which, unfortunately, are not tagged on either side
-			// who would believe it?
-			if item.Configuration.Description == rdsENI {
-				located.parent = &LocatedItem{
-					ConfigurationItem: &load.ConfigurationItem{
-						ResourceType: resourceTypeRDSInstance,
-					},
-				}
-			}
-
-			var (
-				clusterName string
-				eniTag      bool
-				nodeId      string
-			)
-			for tagName, tagValue := range item.Tags {
-				if strings.HasPrefix(tagName, eksClusterOwnerTagNamePrefix) && tagValue == owned {
-					clusterName = strings.TrimPrefix(tagName, eksClusterOwnerTagNamePrefix)
-				}
-				if tagName == eksEniOwnerTagName && tagValue == eksEniOwnerTagValue {
-					eniTag = true
-				}
-				if tagName == k8sInstanceTag {
-					nodeId = tagValue
-				}
-			}
-			switch {
-			case eniTag && clusterName != "":
-				// this is an EKS-created ENI
-				// find the parent, and mark it
-				if resources, ok := itemToLocation[resourceTypeEksCluster]; ok {
-					if parent, ok := resources[clusterName]; ok {
-						located.parent = parent
-					}
-				}
-			case nodeId != "":
-				// this is a EC2 instance-created ENI
-				// find the parent, and mark it
-				if resources, ok := itemToLocation[resourceTypeEC2Instance]; ok {
-					if parent, ok := resources[nodeId]; ok {
-						located.parent = parent
-					}
-				}
-			}
-		}
-
-		// EC2-created instances
-		if item.ResourceType == resourceTypeEC2Instance {
-			for _, rel := range item.Relationships {
-				if rel.ResourceType == resourceTypeENI {
-					// find the parent, and mark it
-					if resources, ok := itemToLocation[resourceTypeENI]; ok {
-						if eni, ok := resources[rel.ResourceID]; ok {
-							eni.parent = located
-						}
-					}
-				}
-			}
+		if located.Deleted() || (located.ResourceType != resourceTypeStack && located.ResourceType != resourceTypeElasticBeanstalk) {
+			return
 		}
-
-		// VPC-Endpoint-owned ENIs
-		if item.ResourceType == resourceTypeVPCEndpoint {
-			// we will just create resources for these associations, as that is how AWSConfig
-			// sees it
-			subType := resourceTypeENI
-			if _, ok := itemToLocation[subType]; !ok {
-				itemToLocation[subType] = make(map[string]*LocatedItem)
-			}
-			if _, ok := nameToLocation[subType]; !ok {
-				nameToLocation[subType] = make(map[string]*LocatedItem)
-			}
-			for _, eni := range item.Configuration.NetworkInterfaceIDs {
-				var (
-					detail *LocatedItem
-					ok     bool
-				)
-				if detail, ok = itemToLocation[subType][eni]; !ok {
-					log.Warnf("found unknown resource: %s %s", subType, eni)
-					continue
-				}
-				detail.parent = located
-			}
+		reason := OwnershipReasonStackContains
+		if located.ResourceType == resourceTypeElasticBeanstalk {
+			reason = OwnershipReasonBeanstalkContains
 		}
-
-		// EC2-instance owned volumes
-		if item.ResourceType == resourceTypeEBSVolume {
-			// in cases where it is explicitly owned by an EKS cluster
-			var clusterName string
-			for tagName, tagValue := range item.Tags {
-				if strings.HasPrefix(tagName, eksClusterOwnerTagNamePrefix) && tagValue == owned {
-					clusterName = strings.TrimPrefix(tagName, eksClusterOwnerTagNamePrefix)
-				}
-				break
-			}
-			if clusterName != "" {
-				// this is an EKS-created ENI
-				// find the parent, and mark it
-				if resources, ok := itemToLocation[resourceTypeEksCluster]; ok {
-					if parent, ok := resources[clusterName]; ok {
-						located.parent = parent
-					}
-				}
+		// track subsidiary resources
+		for _, resource := range located.Relationships {
+			if resource.ResourceType == "" {
+				log.Warnf("AWS Config snapshot: empty resource type for item %s", resource.ResourceID)
 				continue
 			}
-
-			// indicate that it is owned by whatever it is attached to
-			for _, resource := range item.Relationships {
-				if resource.ResourceType == "" {
-					log.Warnf("AWS Config snapshot: empty resource type for item %s", resource.ResourceID)
-					continue
-				}
-				// only care about those attached-to
-				if strings.TrimSpace(resource.Name) != resourceAttachedToInstance {
-					continue
-				}
-				if _, ok := itemToLocation[resource.ResourceType]; !ok {
-					itemToLocation[resource.ResourceType] = make(map[string]*LocatedItem)
-				}
-				key := resource.ResourceID
-				if key == "" {
-					key = resource.ResourceName
-				}
-				var (
-					detail *LocatedItem
-				)
-				if detail, ok = itemToLocation[resource.ResourceType][key]; !ok {
-					// try by name
-					if detail, ok = nameToLocation[resource.ResourceType][key]; !ok {
-						log.Warnf("found unknown resource: %s %s", resource.ResourceType, key)
-						continue
-					}
-				}
-				located.parent = detail
-			}
-		}
-
-		// ASG-owned ec2 instances
-		if item.ResourceType == resourceTypeASG {
-			// indicate that it is owned by whatever it is attached to
-			for _, instance := range item.Configuration.Instances {
-				if _, ok := itemToLocation[resourceTypeEC2Instance]; !ok {
-					itemToLocation[resourceTypeEC2Instance] = make(map[string]*LocatedItem)
-				}
-				var (
-					detail *LocatedItem
-				)
-				if detail, ok = itemToLocation[resourceTypeEC2Instance][instance.InstanceID]; !ok {
-					log.Warnf("found unknown resource: %s %s", resourceTypeEC2Instance, key)
-					continue
-				}
-				detail.parent = located
-			}
-		}
-
-		// cloudwatch alarms
-		if item.ResourceType == resourceTypeAlarm {
-			switch item.Configuration.Namespace {
-			case cloudWatchNamespaceELB:
-				// find the ELB
-				var lbID string
-				for _, dim := range item.Configuration.Dimensions {
-					if dim.Name != dimensionLoadBalancerName {
-						continue
-					}
-					lbID = dim.Value
-					break
-				}
-				if elbMap, ok := itemToLocation[resourceTypeELB]; ok {
-					if elb, ok := elbMap[lbID]; ok {
-						located.parent = elb
-					}
-
-				}
-			}
-		}
-
-		// EC2Fleets can be owned by a LaunchTemplate
-		if item.ResourceType == resourceTypeEC2Fleet {
-			for _, ltConfig := range item.Configuration.LaunchTemplateConfigs {
-				if ltConfig.LaunchTemplateSpecification.LaunchTemplateID != "" {
-					if _, ok := itemToLocation[resourceTypeLaunchTemplate]; ok {
-						if lt, ok := itemToLocation[resourceTypeLaunchTemplate][ltConfig.LaunchTemplateSpecification.LaunchTemplateID]; ok {
-							located.parent = lt
-						}
-					}
-				}
-			}
-		}
-
-		// EKS-created SecurityGroups
-		if item.ResourceType == resourceTypeSecurityGroup {
-			var (
-				clusterName string
-			)
-			for tagName, tagValue := range item.Tags {
-				if strings.HasPrefix(tagName, eksClusterOwnerTagNamePrefix) && tagValue == owned {
-					clusterName = strings.TrimPrefix(tagName, eksClusterOwnerTagNamePrefix)
-				}
-				break
-			}
-			if clusterName != "" {
-				// this is an EKS-created ENI
-				// find the parent, and mark it
-				if resources, ok := itemToLocation[resourceTypeEksCluster]; ok {
-					if parent, ok := resources[clusterName]; ok {
-						located.parent = parent
-					}
-				}
-			}
-		}
-
-		if item.ResourceType == resourceTypeIAMRole {
-			// look for service-linked roles
-			if strings.HasPrefix(item.Configuration.Path, serviceLinkedRolePathPrefix) {
-				service := strings.TrimPrefix(item.Configuration.Path, serviceLinkedRolePathPrefix)
-				// trim the final / if it exists
-				if service[len(service)-1] == '/' {
-					service = service[:len(service)-1]
-				}
-
-				// this is the name of the role; create a parent for this IAM Role as that service
-				if _, ok := itemToLocation[resourceTypeService]; !ok {
-					itemToLocation[resourceTypeService] = make(map[string]*LocatedItem)
-				}
-				if _, ok := itemToLocation[resourceTypeService][service]; !ok {
-					itemToLocation[resourceTypeService][service] = &LocatedItem{
-						ConfigurationItem: &load.ConfigurationItem{
-							ResourceType: resourceTypeService,
-							ResourceID:   service,
-						},
-					}
-				}
-				located.parent = itemToLocation[resourceTypeService][service]
-			}
-		}
-
-		// EKS-created ELB
-		if item.ResourceType == resourceTypeELB {
-			var (
-				clusterName string
-			)
-			for tagName, tagValue := range item.Tags {
-				if strings.HasPrefix(tagName, eksClusterOwnerTagNamePrefix) && tagValue == owned {
-					clusterName = strings.TrimPrefix(tagName, eksClusterOwnerTagNamePrefix)
-				}
-				break
+			// only care about those contained
+			if strings.TrimSpace(resource.Name) != resourceContains {
+				continue
 			}
-			if clusterName != "" {
-				// this is an EKS-created ELB
-				// find the parent, and mark it
-				if resources, ok := itemToLocation[resourceTypeEksCluster]; ok {
-					if parent, ok := resources[clusterName]; ok {
-						located.parent = parent
-					}
-				}
+			key := resource.ResourceID
+			if key == "" {
+				key = resource.ResourceName
 			}
+			containedItem(idx.scoped(located.Scope()), located, resource.ResourceType, resource.ResourceID, key).setParent(located, reason)
 		}
 
-		// EKS-created ELBv2
-		if item.ResourceType == resourceTypeELBV2 {
-			var clusterName string
-			for tagName, tagValue := range item.Tags {
-				if tagName == eksELBCluster {
-					clusterName = tagValue
-					break
-				}
+		for _, resource := range idx.detail(located).SupplementaryConfiguration.UnsupportedResources {
+			if resource.ResourceType == "" {
+				log.Warnf("AWS Config snapshot: empty resource type for item %s", resource.ResourceID)
+				continue
 			}
-			if clusterName != "" {
-				// this is an EKS-created ELB
-				// find the parent, and mark it
-				if resources, ok := itemToLocation[resourceTypeEksCluster]; ok {
-					if parent, ok := resources[clusterName]; ok {
-						located.parent = parent
-						continue
-					}
-				}
-				// did not find it? try by name
-				if resources, ok := nameToLocation[resourceTypeEksCluster]; ok {
-					if parent, ok := resources[clusterName]; ok {
-						located.parent = parent
-					}
-				}
+			if resource.ResourceID == "" {
+				log.Warnf("AWS Config snapshot: empty resource ID for item %s", resource.ResourceType)
+				continue
 			}
+			containedItem(idx.scoped(located.Scope()), located, resource.ResourceType, resource.ResourceID, resource.ResourceID).setParent(located, reason)
 		}
+	})
 
-		// RDS Cluster Snapshots are owned by the clusters
-		if item.ResourceType == resourceTypeRDSClusterSnapshot {
-			if _, ok := itemToLocation[resourceTypeRDSCluster]; ok {
-				if cluster, ok := itemToLocation[resourceTypeRDSCluster][item.Configuration.DBClusterIdentifier]; ok {
-					located.parent = cluster
-				} else {
-					if cluster, ok := nameToLocation[resourceTypeRDSCluster][item.Configuration.DBClusterIdentifier]; ok {
-						located.parent = cluster
-					}
-				}
-			}
+	// third pass for resources that are owned by others, according to the ownership rules
+	idx.each(configured, func(located *LocatedItem) {
+		if located.Deleted() || !engine.applies(located.ResourceType) {
+			return
 		}
-
-		// ENIs that are owned by an ELB and other resources
-		if item.ResourceType == resourceTypeENI {
-			switch {
-			case item.Configuration.InterfaceType == lambdaInterfaceType && strings.HasPrefix(item.Configuration.Description, lambdaPrefix):
-				lambdaName := strings.TrimPrefix(item.Configuration.Description, lambdaPrefix)
-				// lambda also includes a UUID at the end, so we need to remove that
-				if len(lambdaName) > uuidSize {
-					// check if it finishes with a UUID
-					if _, err := uuid.Parse(lambdaName[len(lambdaName)-uuidSize:]); err == nil {
-						lambdaName = lambdaName[:len(lambdaName)-uuidSize]
-						// remove last -
-						if lambdaName[len(lambdaName)-1] == '-' {
-							lambdaName = lambdaName[:len(lambdaName)-1]
-						}
-					}
-				}
-				// now find the correct lambda
-				if lambdaMap, ok := itemToLocation[resourceTypeLambda]; ok {
-					if lambda, ok := lambdaMap[lambdaName]; ok {
-						located.parent = lambda
-					}
-				}
-
-			case strings.HasPrefix(item.Configuration.Description, elbPrefix) &&
-				(item.Configuration.Association.IPOwnerID == awsELBOwner ||
-					item.Configuration.Attachment.InstanceOwnerID == awsELBOwner ||
-					item.Configuration.InterfaceType == nlb):
-				// find the ELB that owns it, make it the parent
-				region := item.Region
-				account := item.AccountID
-				elbName := strings.TrimPrefix(item.Configuration.Description, elbPrefix)
-				// could be ELB or ELBv2; nothing in it indicates that it is, except perhaps the start of the name,
-				// so we might as well just check both
-				var found bool
-				if elbMap, ok := itemToLocation[resourceTypeELB]; ok {
-					if elb, ok := elbMap[elbName]; ok {
-						located.parent = elb
-						found = true
-					}
-				}
-				if !found {
-					if region != "" && account != "" {
-						nlbArn := fmt.Sprintf("%s:%s:%s:loadbalancer/%s", elbArnPrefix, region, account, elbName)
-						if elbMap, ok := itemToLocation[resourceTypeELBV2]; ok {
-							if elb, ok := elbMap[nlbArn]; ok {
-								located.parent = elb
-							}
-						}
-					}
-				}
-			case item.Configuration.InterfaceType == natGatewayInterfaceType && strings.HasPrefix(item.Configuration.Description, natGatewayPrefix):
-				itemName := strings.TrimPrefix(item.Configuration.Description, natGatewayPrefix)
-				// now find the correct NAT Gateway
-				if itemMap, ok := itemToLocation[resourceTypeNATGateway]; ok {
-					if item, ok := itemMap[itemName]; ok {
-						located.parent = item
-					}
-				}
-			case strings.HasPrefix(item.Configuration.Description, elastiCachePrefix):
-				itemName := strings.TrimPrefix(item.Configuration.Description, elastiCachePrefix)
-				// now find the correct ElastiCache Cluster
-				if itemMap, ok := itemToLocation[resourceTypeElastiCacheCluster]; ok {
-					if item, ok := itemMap[itemName]; ok {
-						located.parent = item
-					}
-				}
-			case item.Configuration.InterfaceType == transitGatewayInterfaceType && strings.HasPrefix(item.Configuration.Description, transitGatewayPrefix):
-				itemName := strings.TrimPrefix(item.Configuration.Description, transitGatewayPrefix)
-				// now find the correct ElastiCache Cluster
-				if itemMap, ok := itemToLocation[resourceTypeTransitGatewayAttachment]; ok {
-					if item, ok := itemMap[itemName]; ok {
-						located.parent = item
-					}
-				}
-			}
-		}
-	}
+		// the rules read the configuration of the item, so they are given all of it
+		record := located.ConfigurationItem
+		located.ConfigurationItem = idx.detail(located)
+		engine.apply(located, idx.scoped(located.Scope()))
+		located.ConfigurationItem = record
+	})
 
 	// now comes the harder part. We have to go through each tfstate and reconcile it with the snapshot
 	// This would be easy if there were standards, but everything is driven by the provider,
 	// terraform itself has no standard or intelligence about it, so we need to know all of them.
 	for statefile, tfstate := range tfstates {
+		// the scope of resources whose ARN does not include the account or region
+		stateScope := o.terraformScopes[statefile].merge(inferStateScope(tfstate))
 		for i, resource := range tfstate.Resources {
 			// only care about managed resources
 			if resource.Mode != load.TerraformManaged {
 				continue
 			}
-			// only care about aws resources
-			if resource.Provider != terraformAWSProvider &&
-				resource.Provider != terraformAWSRegistryProvider &&
-				!strings.HasSuffix(resource.Provider, terraformAWSProvider) &&
-				!strings.HasSuffix(resource.Provider, terraformAWSRegistryProvider) {
+			// only care about aws resources, but count the others, so that none are silently dropped
+			if source := ProviderSource(resource.Provider); !providers.match(source) {
+				stats.SkippedProviders[source] += len(resource.Instances)
 				continue
 			}
 			// look up the resource type
@@ -640,10 +402,9 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 				configType = resource.Type
 				mappedType = false
 			}
-			if _, ok := itemToLocation[configType]; !ok {
-				itemToLocation[configType] = make(map[string]*LocatedItem)
-			}
 			for j, instance := range resource.Instances {
+				// nothing refers to the items of the previous instance any more
+				idx.release()
 				var (
 					resourceId, arn, name string
 					item                  *LocatedItem
@@ -663,6 +424,12 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 				if namePtr != nil {
 					name = namePtr.(string)
 				}
+				scope := ScopeFromARN(arn)
+				if region, ok := instance.Attributes["region"].(string); ok && scope.Region == "" {
+					scope.Region = region
+				}
+				scope = scope.merge(stateScope).forResource(configType, arn)
+				scoped := idx.scoped(scope)
 
 				switch {
 				case arn != "":
@@ -692,16 +459,8 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 					if securityGroupIDPtr != nil {
 						securityGroupID = securityGroupIDPtr.(string)
 					}
-					fromPortPtr := instance.Attributes["from_port"]
-					if fromPortPtr != nil {
-						fromPortFloat := fromPortPtr.(float64)
-						fromPort = int64(fromPortFloat)
-					}
-					toPortPtr := instance.Attributes["to_port"]
-					if toPortPtr != nil {
-						toPortFloat := toPortPtr.(float64)
-						toPort = int64(toPortFloat)
-					}
+					fromPort = intAttribute(instance.Attributes["from_port"])
+					toPort = intAttribute(instance.Attributes["to_port"])
 					protocolPtr := instance.Attributes["protocol"]
 					if protocolPtr != nil {
 						protocol = protocolPtr.(string)
@@ -732,8 +491,8 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 					// find the security group in Config based on the ID
 					if securityGroupID != "" {
 						// if we could not find the security group, then nothing to look for in Config; it only is in terraform
-						if securityGroup, ok = itemToLocation[resourceTypeSecurityGroup][securityGroupID]; !ok {
-							if securityGroup, ok = nameToLocation[resourceTypeSecurityGroup][securityGroupID]; !ok {
+						if securityGroup, ok = scoped.Get(resourceTypeSecurityGroup, securityGroupID); !ok {
+							if securityGroup, ok = scoped.GetByName(resourceTypeSecurityGroup, securityGroupID); !ok {
 								securityGroup = nil
 							}
 						}
@@ -750,9 +509,9 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 						var ruleset []load.IPPermission
 						switch ruleType {
 						case ingress:
-							ruleset = securityGroup.Configuration.IPPermissions
+							ruleset = idx.detail(securityGroup).Configuration.IPPermissions
 						case egress:
-							ruleset = securityGroup.Configuration.IPPermissionsEgress
+							ruleset = idx.detail(securityGroup).Configuration.IPPermissionsEgress
 						default:
 							// unknown rule type, so just skip it
 							log.Warnf("unknown security group rule type %s for resource %d, instance %d in file %s", ruleType, i, j, statefile)
@@ -844,15 +603,15 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 					// find the route table in Config based on the ID
 					if routeTableID != "" {
 						// if we could not find the route table, then nothing to look for in Config; it only is in terraform
-						if routeTable, ok = itemToLocation[resourceTypeRouteTable][routeTableID]; !ok {
-							if routeTable, ok = nameToLocation[resourceTypeRouteTable][routeTableID]; !ok {
+						if routeTable, ok = scoped.Get(resourceTypeRouteTable, routeTableID); !ok {
+							if routeTable, ok = scoped.GetByName(resourceTypeRouteTable, routeTableID); !ok {
 								routeTable = nil
 							}
 						}
 					}
 					// we found the parent route table, look through the routes and find the one that matches
 					if routeTable != nil {
-						for _, route := range routeTable.Configuration.Routes {
+						for _, route := range idx.detail(routeTable).Configuration.Routes {
 							if route.DestinationCIDRBlock == instance.Attributes["destination_cidr_block"] &&
 								route.Origin == instance.Attributes["origin"] &&
 								route.VPCPeeringConnectionID == instance.Attributes["vpc_peering_connection_id"] &&
@@ -879,14 +638,14 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 						policyID = policyPtr.(string)
 					}
 					if roleID != "" {
-						if role, ok = itemToLocation[resourceTypeIAMRole][roleID]; !ok {
-							if role, ok = nameToLocation[resourceTypeIAMRole][roleID]; !ok {
+						if role, ok = scoped.Get(resourceTypeIAMRole, roleID); !ok {
+							if role, ok = scoped.GetByName(resourceTypeIAMRole, roleID); !ok {
 								role = nil
 							}
 						}
 					}
 					if policyID != "" {
-						if policy, ok = arnToLocation[resourceTypeIAMPolicy][policyID]; !ok {
+						if policy, ok = scoped.GetByARN(resourceTypeIAMPolicy, policyID); !ok {
 							policy = nil
 						}
 					}
@@ -913,24 +672,15 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 
 					// find the route table in Config based on the ID
 					if naclID != "" {
-						if nacl, ok = itemToLocation[resourceTypeNetworkACL][naclID]; !ok {
-							if nacl, ok = nameToLocation[resourceTypeNetworkACL][naclID]; !ok {
+						if nacl, ok = scoped.Get(resourceTypeNetworkACL, naclID); !ok {
+							if nacl, ok = scoped.GetByName(resourceTypeNetworkACL, naclID); !ok {
 								nacl = nil
 							}
 						}
 					}
 					// we found the parent NACL table, look through the rules and find the one that matches
 					if nacl != nil {
-						for _, entry := range nacl.Configuration.Entries {
-							if entry.CidrBlock == instance.Attributes["cidr_block"] &&
-								entry.Egress == instance.Attributes["egress"] &&
-								entry.Protocol == instance.Attributes["protocol"] &&
-								entry.RuleAction == instance.Attributes["rule_action"] &&
-								entry.RuleNumber == instance.Attributes["rule_number"] {
-								parentFound = true
-								break
-							}
-						}
+						parentFound = naclEntryMatches(idx.detail(nacl).Configuration.Entries, instance.Attributes)
 					}
 				case terraformTypeASGAttachment:
 					// check if the ASG exists
@@ -946,15 +696,15 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 
 					// find the target group in the ASG
 					if asgID != "" {
-						if asg, ok = itemToLocation[resourceTypeASG][asgID]; !ok {
-							if asg, ok = nameToLocation[resourceTypeASG][asgID]; !ok {
+						if asg, ok = scoped.Get(resourceTypeASG, asgID); !ok {
+							if asg, ok = scoped.GetByName(resourceTypeASG, asgID); !ok {
 								asg = nil
 							}
 						}
 					}
 					// we found the parent ASG, look through the attachments and find the one that matches
 					if asg != nil {
-						for _, tg := range asg.Configuration.TargetGroupARNs {
+						for _, tg := range idx.detail(asg).Configuration.TargetGroupARNs {
 							if tg == instance.Attributes["alb_target_group_arn"] || tg == instance.Attributes["lb_target_group_arn"] {
 								parentFound = true
 								break
@@ -966,9 +716,9 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 					// route53 record sets are not yet supported in AWS Config
 					parentFound = true
 				default:
-					if item, ok = itemToLocation[configType][key]; !ok {
-						if item, ok = nameToLocation[configType][name]; !ok {
-							if item, ok = arnToLocation[configType][arn]; !ok {
+					if item, ok = scoped.Get(configType, key); !ok {
+						if item, ok = scoped.GetByName(configType, name); !ok {
+							if item, ok = scoped.GetByARN(configType, arn); !ok {
 								item = nil
 							}
 						}
@@ -981,22 +731,133 @@ func Reconcile(snapshot load.Snapshot, tfstates map[string]load.TerraformState)
 							ResourceType: configType,
 							ResourceID:   resourceId,
 							ARN:          arn,
+							AccountID:    scope.AccountID,
+							Region:       scope.Region,
 						},
 						mappedType: mappedType,
 					}
-					itemToLocation[configType][key] = item
+					scoped.Add(key, item)
 				}
 				if item != nil {
-					item.terraform = true
+					item.addSource(SourceTerraform)
+					item.terraformResources = append(item.terraformResources, TerraformResource{
+						Statefile:  statefile,
+						Module:     resource.Module,
+						Type:       resource.Type,
+						Name:       resource.Name,
+						IndexKey:   instance.IndexKey,
+						attributes: instance.Attributes,
+					})
 				}
 			}
 		}
 	}
 
-	for _, locations := range itemToLocation {
-		for _, item := range locations {
+	// other IaC sources, which are simpler than terraform, as each resource is already of a known type
+	for _, resource := range o.iacResources {
+		reconcileIaCResource(idx, resource)
+		idx.release()
+	}
+
+	for source, count := range stats.SkippedProviders {
+		log.Debugf("skipped %d terraform resources of provider %s", count, source)
+	}
+
+	idx.release()
+	for _, item := range idx.all() {
+		// the items of resources that were deleted were only kept for reconciling
+		if !item.Deleted() {
 			items = append(items, item)
 		}
 	}
+	if idx.err != nil {
+		return nil, fmt.Errorf("unable to index resources: %w", idx.err)
+	}
+	if len(o.ignoreRules) > 0 {
+		now := time.Now()
+		for _, item := range items {
+			// only those that otherwise would be drift can be accepted
+			if !item.Source(SourceConfig) || item.Owned() {
+				continue
+			}
+			for _, rule := range o.ignoreRules {
+				if rule.Match(item, now) {
+					item.acceptedBy = rule
+					break
+				}
+			}
+		}
+	}
 	return items, nil
 }
+
+// containedItem find the item contained by a stack, by key, which could be an ID or a name.
+// If it does not exist, it is created with the given id, in the same account and region as the stack.
+func containedItem(idx Index, stack *LocatedItem, resourceType, id, key string) *LocatedItem {
+	if detail, ok := idx.Get(resourceType, key); ok {
+		return detail
+	}
+	// try by name
+	if detail, ok := idx.GetByName(resourceType, key); ok {
+		return detail
+	}
+	detail := &LocatedItem{
+		ConfigurationItem: &load.ConfigurationItem{
+			ResourceType: resourceType,
+			ResourceID:   id,
+			AccountID:    stack.AccountID,
+			Region:       stack.Region,
+		},
+	}
+	idx.Add(id, detail)
+	return detail
+}
+
+// intAttribute the value of a numeric terraform attribute, which is a float64 in version 4
+// states, but a string in legacy ones; 0 if missing or not a number.
+func intAttribute(v interface{}) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	default:
+		return 0
+	}
+}
+
+// attributeString the value of a scalar terraform attribute as a string, and whether it is set.
+func attributeString(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case string:
+		return value, true
+	case bool:
+		return strconv.FormatBool(value), true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// naclEntryMatches whether any of the entries of a network ACL is the rule of an
+// aws_network_acl_rule with the attributes. They are compared as strings, as legacy states
+// have only strings, and json numbers are float64.
+func naclEntryMatches(entries []load.Entry, attributes map[string]interface{}) bool {
+	cidrBlock, _ := attributeString(attributes["cidr_block"])
+	egress, _ := attributeString(attributes["egress"])
+	protocol, _ := attributeString(attributes["protocol"])
+	ruleAction, _ := attributeString(attributes["rule_action"])
+	ruleNumber := intAttribute(attributes["rule_number"])
+	for _, entry := range entries {
+		if entry.CidrBlock == cidrBlock &&
+			strconv.FormatBool(entry.Egress) == egress &&
+			entry.Protocol == protocol &&
+			entry.RuleAction == ruleAction &&
+			entry.RuleNumber == ruleNumber {
+			return true
+		}
+	}
+	return false
+}
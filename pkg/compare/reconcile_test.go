@@ -0,0 +1,125 @@
+package compare
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+func TestNACLEntryMatches(t *testing.T) {
+	entries := []load.Entry{
+		{CidrBlock: "10.0.0.0/16", Egress: false, Protocol: "6", RuleAction: "allow", RuleNumber: 100},
+		{CidrBlock: "0.0.0.0/0", Egress: true, Protocol: "-1", RuleAction: "deny", RuleNumber: 32766},
+	}
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		want       bool
+	}{
+		{
+			"v4 state",
+			map[string]interface{}{"cidr_block": "10.0.0.0/16", "egress": false, "protocol": "6", "rule_action": "allow", "rule_number": float64(100)},
+			true,
+		},
+		{
+			"legacy state",
+			map[string]interface{}{"cidr_block": "0.0.0.0/0", "egress": "true", "protocol": "-1", "rule_action": "deny", "rule_number": "32766"},
+			true,
+		},
+		{
+			"other rule number",
+			map[string]interface{}{"cidr_block": "10.0.0.0/16", "egress": false, "protocol": "6", "rule_action": "allow", "rule_number": float64(200)},
+			false,
+		},
+		{
+			"other direction",
+			map[string]interface{}{"cidr_block": "10.0.0.0/16", "egress": "true", "protocol": "6", "rule_action": "allow", "rule_number": "100"},
+			false,
+		},
+		{
+			"other action",
+			map[string]interface{}{"cidr_block": "0.0.0.0/0", "egress": true, "protocol": "-1", "rule_action": "allow", "rule_number": float64(32766)},
+			false,
+		},
+		{"no attributes", map[string]interface{}{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := naclEntryMatches(entries, tt.attributes); got != tt.want {
+				t.Errorf("naclEntryMatches() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntAttribute(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want int64
+	}{
+		{"number", float64(42), 42},
+		{"string", "42", 42},
+		{"not a number", "forty-two", 0},
+		{"missing", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intAttribute(tt.v); got != tt.want {
+				t.Errorf("intAttribute(%v) = %d, want %d", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileLatestItems(t *testing.T) {
+	t1 := load.Timestamp{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	t2 := load.Timestamp{Time: t1.Add(time.Hour)}
+	bucket := func(region string, captured load.Timestamp, status, version string) load.ConfigurationItem {
+		return load.ConfigurationItem{
+			ResourceType: "AWS::S3::Bucket",
+			ResourceID:   "logs",
+			AccountID:    "123456789012",
+			Region:       region,
+			Status:       status,
+			CaptureTime:  captured,
+			Tags:         map[string]string{"version": version},
+		}
+	}
+	tests := []struct {
+		name    string
+		items   []load.ConfigurationItem
+		want    []string
+		deleted int
+	}{
+		{"later read later", []load.ConfigurationItem{bucket("us-east-1", t1, load.StatusOK, "1"), bucket("us-east-1", t2, load.StatusOK, "2")}, []string{"us-east-1 2"}, 0},
+		{"later read first", []load.ConfigurationItem{bucket("us-east-1", t2, load.StatusOK, "2"), bucket("us-east-1", t1, load.StatusOK, "1")}, []string{"us-east-1 2"}, 0},
+		{"deleted", []load.ConfigurationItem{bucket("us-east-1", t1, load.StatusOK, "1"), bucket("us-east-1", t2, load.StatusResourceDeleted, "")}, nil, 1},
+		// an older item read after the deletion does not revive the resource
+		{"deleted read first", []load.ConfigurationItem{bucket("us-east-1", t2, load.StatusResourceDeleted, ""), bucket("us-east-1", t1, load.StatusOK, "1")}, nil, 1},
+		{"other region", []load.ConfigurationItem{bucket("us-east-1", t1, load.StatusOK, "1"), bucket("eu-west-1", t2, load.StatusOK, "2")}, []string{"eu-west-1 2", "us-east-1 1"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats Stats
+			items, err := Reconcile(load.Snapshot{ConfigurationItems: tt.items}, nil, WithStats(&stats))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range items {
+				got = append(got, item.Region+" "+item.Tags["version"])
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+			if stats.DeletedResources != tt.deleted {
+				t.Errorf("DeletedResources = %d, want %d", stats.DeletedResources, tt.deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package compare
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OwnershipRule infers the owner of an AWS Config item that might not be managed directly
+// by IaC, e.g. an ENI created by an EKS cluster, or an EC2 instance created by an ASG.
+// Reconcile applies each enabled rule to every item of its ResourceTypes, in the order
+// in which the rules were registered. If more than one rule finds an owner for the same
+// item, the last one wins.
+type OwnershipRule interface {
+	// Name unique name of the rule, used to enable or disable it.
+	Name() string
+	// ResourceTypes the AWS Config resource types of the items to which the rule applies.
+	ResourceTypes() []string
+	// Apply apply the rule to an item of one of the ResourceTypes, returning the owners found, if any.
+	// The item itself need not be the child; e.g. an ASG rule is applied to the ASG, and returns
+	// each of its instances as a child. The items found in the Index may be only lean records,
+	// whose configuration is returned by LocatedItem.Detail.
+	Apply(item *LocatedItem, idx Index) []Ownership
+}
+
+// Ownership a child owned by a parent, and the reason it is considered owned.
+type Ownership struct {
+	Child  *LocatedItem
+	Parent *LocatedItem
+	Reason string
+}
+
+// ownershipRules registered rules, in registration order
+var ownershipRules []OwnershipRule
+
+// RegisterOwnershipRule register a rule, to be applied by every call to Reconcile, after
+// all previously registered rules. Returns an error if a rule of the same name already exists.
+func RegisterOwnershipRule(rule OwnershipRule) error {
+	for _, existing := range ownershipRules {
+		if existing.Name() == rule.Name() {
+			return fmt.Errorf("ownership rule %s already registered", rule.Name())
+		}
+	}
+	ownershipRules = append(ownershipRules, rule)
+	return nil
+}
+
+// OwnershipRules the registered rules, in registration order.
+func OwnershipRules() []OwnershipRule {
+	return append([]OwnershipRule(nil), ownershipRules...)
+}
+
+// OwnershipRuleNames the names of the registered rules, sorted.
+func OwnershipRuleNames() []string {
+	var names []string
+	for _, rule := range ownershipRules {
+		names = append(names, rule.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ruleEngine the rules enabled for a single Reconcile, by resource type
+type ruleEngine struct {
+	byType map[string][]OwnershipRule
+}
+
+// newRuleEngine create an engine with the given rules, other than those disabled.
+// Returns an error if two rules have the same name, or if a disabled rule does not exist,
+// as it is most likely a typo.
+func newRuleEngine(rules []OwnershipRule, disabled map[string]bool) (*ruleEngine, error) {
+	var (
+		engine = &ruleEngine{byType: make(map[string][]OwnershipRule)}
+		found  = make(map[string]bool)
+	)
+	for _, rule := range rules {
+		if found[rule.Name()] {
+			return nil, fmt.Errorf("duplicate ownership rule %s", rule.Name())
+		}
+		found[rule.Name()] = true
+		if disabled[rule.Name()] {
+			continue
+		}
+		for _, resourceType := range rule.ResourceTypes() {
+			engine.byType[resourceType] = append(engine.byType[resourceType], rule)
+		}
+	}
+	for name := range disabled {
+		if !found[name] {
+			return nil, fmt.Errorf("unknown ownership rule %s", name)
+		}
+	}
+	return engine, nil
+}
+
+// applies whether any of the rules applies to items of the type
+func (e *ruleEngine) applies(resourceType string) bool {
+	return len(e.byType[resourceType]) > 0
+}
+
+// apply apply all of the rules for the type of the item
+func (e *ruleEngine) apply(item *LocatedItem, idx Index) {
+	for _, rule := range e.byType[item.ResourceType] {
+		for _, ownership := range rule.Apply(item, idx) {
+			if ownership.Child == nil || ownership.Parent == nil {
+				continue
+			}
+			ownership.Child.setParent(ownership.Parent, ownership.Reason)
+		}
+	}
+}
+
+// ownershipRuleFunc an OwnershipRule implemented by a function
+type ownershipRuleFunc struct {
+	name  string
+	types []string
+	apply func(item *LocatedItem, idx Index) []Ownership
+}
+
+func (o ownershipRuleFunc) Name() string {
+	return o.name
+}
+
+func (o ownershipRuleFunc) ResourceTypes() []string {
+	return o.types
+}
+
+func (o ownershipRuleFunc) Apply(item *LocatedItem, idx Index) []Ownership {
+	return o.apply(item, idx)
+}
@@ -0,0 +1,202 @@
+package compare
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+// globalRegion the region AWS Config uses for global resources, e.g. IAM
+const globalRegion = "global"
+
+// Scope the account and region of an item. Empty fields are unknown, and match any value.
+type Scope struct {
+	AccountID string `json:"accountId,omitempty"`
+	Region    string `json:"region,omitempty"`
+}
+
+// Matches whether two scopes could be the same, i.e. they do not have different
+// accounts, or different regions. The global region matches any region.
+func (s Scope) Matches(other Scope) bool {
+	if s.AccountID != "" && other.AccountID != "" && s.AccountID != other.AccountID {
+		return false
+	}
+	if s.Region != "" && other.Region != "" && s.Region != globalRegion && other.Region != globalRegion && s.Region != other.Region {
+		return false
+	}
+	return true
+}
+
+// merge fill in the unknown fields of the scope from the other
+func (s Scope) merge(other Scope) Scope {
+	if s.AccountID == "" {
+		s.AccountID = other.AccountID
+	}
+	if s.Region == "" {
+		s.Region = other.Region
+	}
+	return s
+}
+
+// ScopeFromARN get the scope from an ARN, i.e. arn:partition:service:region:account-id:resource.
+// Either field may be empty, e.g. IAM ARNs have no region, and S3 ARNs have neither.
+func ScopeFromARN(arn string) Scope {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return Scope{}
+	}
+	return Scope{AccountID: parts[4], Region: parts[3]}
+}
+
+// globalResource whether AWS Config records the resource, of the type and with the ARN, in the
+// global region: IAM resources, whose ARNs have no region, whichever region they are managed from.
+// The type may be that of AWS Config, or, for types it does not record, that of terraform.
+func globalResource(resourceType, arn string) bool {
+	if strings.HasPrefix(resourceType, "AWS::IAM::") || strings.HasPrefix(resourceType, "aws_iam_") {
+		return true
+	}
+	parts := strings.SplitN(arn, ":", 4)
+	return len(parts) == 4 && parts[0] == "arn" && parts[2] == "iam"
+}
+
+// forResource the scope of a resource of the type, with the ARN; global resources are in the
+// global region, as AWS Config records them, rather than in the region of the IaC.
+func (s Scope) forResource(resourceType, arn string) Scope {
+	if globalResource(resourceType, arn) {
+		s.Region = globalRegion
+	}
+	return s
+}
+
+// inferStateScope infer the scope of a terraform state, from the most common account and
+// region in the ARNs of its resources. Most states manage a single account and region.
+func inferStateScope(state load.TerraformState) Scope {
+	var (
+		accounts = make(map[string]int)
+		regions  = make(map[string]int)
+	)
+	for _, resource := range state.Resources {
+		if resource.Mode != load.TerraformManaged {
+			continue
+		}
+		for _, instance := range resource.Instances {
+			arn, _ := instance.Attributes["arn"].(string)
+			scope := ScopeFromARN(arn)
+			if scope.AccountID != "" {
+				accounts[scope.AccountID]++
+			}
+			if scope.Region != "" {
+				regions[scope.Region]++
+			}
+		}
+	}
+	return Scope{AccountID: mostCommon(accounts), Region: mostCommon(regions)}
+}
+
+// mostCommon the key with the highest count; ties go to the lowest key, so it is deterministic
+func mostCommon(counts map[string]int) string {
+	var (
+		best      string
+		bestCount int
+	)
+	for key, count := range counts {
+		if count > bestCount || (count == bestCount && key < best) {
+			best, bestCount = key, count
+		}
+	}
+	return best
+}
+
+// ways to group items by scope
+const (
+	GroupByAccount       = "account"
+	GroupByRegion        = "region"
+	GroupByAccountRegion = "account-region"
+)
+
+// GroupByOptions the valid values for SummarizeByScope
+var GroupByOptions = []string{GroupByAccount, GroupByRegion, GroupByAccountRegion}
+
+// ParseScope parse a scope in the form <account>/<region>, either of which may be empty.
+func ParseScope(s string) (Scope, error) {
+	account, region, ok := strings.Cut(s, "/")
+	if !ok {
+		return Scope{}, fmt.Errorf("invalid scope %s, must be <account>/<region>", s)
+	}
+	return Scope{AccountID: account, Region: region}, nil
+}
+
+// String the scope as <account>/<region>.
+func (s Scope) String() string {
+	return s.AccountID + "/" + s.Region
+}
+
+// FilterByScope return only the items in one of the accounts and one of the regions.
+// An empty list matches everything; otherwise, items whose account or region is unknown
+// do not match.
+func FilterByScope(items []*LocatedItem, accounts, regions []string) []*LocatedItem {
+	if len(accounts) == 0 && len(regions) == 0 {
+		return items
+	}
+	var (
+		filtered      []*LocatedItem
+		accountFilter = make(map[string]bool)
+		regionFilter  = make(map[string]bool)
+	)
+	for _, account := range accounts {
+		accountFilter[account] = true
+	}
+	for _, region := range regions {
+		regionFilter[region] = true
+	}
+	for _, item := range items {
+		if len(accountFilter) > 0 && !accountFilter[item.AccountID] {
+			continue
+		}
+		if len(regionFilter) > 0 && !regionFilter[item.Region] {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// ScopeSummary the summary of the items in a single scope. When grouped by account,
+// the Region is empty, and vice versa.
+type ScopeSummary struct {
+	Scope   Scope    `json:"scope"`
+	Summary *Summary `json:"summary"`
+}
+
+// SummarizeByScope summarize the items separately for each scope, grouped by one of
+// GroupByOptions, sorted by scope.
+func SummarizeByScope(items []*LocatedItem, groupBy string) ([]ScopeSummary, error) {
+	groups := make(map[Scope][]*LocatedItem)
+	for _, item := range items {
+		scope := item.Scope()
+		switch groupBy {
+		case GroupByAccount:
+			scope.Region = ""
+		case GroupByRegion:
+			scope.AccountID = ""
+		case GroupByAccountRegion:
+		default:
+			return nil, fmt.Errorf("invalid group by %s, options are: %s", groupBy, strings.Join(GroupByOptions, " "))
+		}
+		groups[scope] = append(groups[scope], item)
+	}
+	var results []ScopeSummary
+	for scope, scopeItems := range groups {
+		summary, err := Summarize(scopeItems)
+		if err != nil {
+			return nil, fmt.Errorf("unable to summarize %s: %w", scope, err)
+		}
+		results = append(results, ScopeSummary{Scope: scope, Summary: summary})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Scope.String() < results[j].Scope.String()
+	})
+	return results, nil
+}
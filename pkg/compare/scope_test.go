@@ -0,0 +1,52 @@
+package compare
+
+import "testing"
+
+func TestScopeForResource(t *testing.T) {
+	scope := Scope{AccountID: "123456789012", Region: "us-east-1"}
+	tests := []struct {
+		name         string
+		resourceType string
+		arn          string
+		want         Scope
+	}{
+		{"config IAM type", "AWS::IAM::Role", "", Scope{AccountID: "123456789012", Region: globalRegion}},
+		{"terraform IAM type", "aws_iam_role_policy_attachment", "", Scope{AccountID: "123456789012", Region: globalRegion}},
+		{"IAM ARN", "AWS::Custom::Thing", "arn:aws:iam::123456789012:role/admin", Scope{AccountID: "123456789012", Region: globalRegion}},
+		{"regional type", "AWS::EC2::Instance", "arn:aws:ec2:us-east-1:123456789012:instance/i-1", scope},
+		{"regional ARN naming IAM", "AWS::SSM::Parameter", "arn:aws:ssm:us-east-1:123456789012:parameter/iam", scope},
+		{"no ARN", "AWS::S3::Bucket", "", scope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scope.forResource(tt.resourceType, tt.arn); got != tt.want {
+				t.Errorf("forResource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Scope
+		want bool
+	}{
+		{"same", Scope{"1", "us-east-1"}, Scope{"1", "us-east-1"}, true},
+		{"unknown account", Scope{"", "us-east-1"}, Scope{"1", "us-east-1"}, true},
+		{"unknown region", Scope{"1", ""}, Scope{"1", "eu-west-1"}, true},
+		{"other account", Scope{"1", "us-east-1"}, Scope{"2", "us-east-1"}, false},
+		{"other region", Scope{"1", "us-east-1"}, Scope{"1", "eu-west-1"}, false},
+		{"global region", Scope{"1", globalRegion}, Scope{"1", "eu-west-1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Matches(tt.b); got != tt.want {
+				t.Errorf("%v.Matches(%v) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+			if got := tt.b.Matches(tt.a); got != tt.want {
+				t.Errorf("%v.Matches(%v) = %t, want %t", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
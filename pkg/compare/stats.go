@@ -0,0 +1,13 @@
+package compare
+
+// Stats statistics of a single call to Reconcile, about inputs that are not part of any item.
+type Stats struct {
+	// SkippedProviders the number of IaC resources that were not reconciled, because they are not
+	// AWS resources, by provider: for terraform, the source address of providers that did not match
+	// any provider pattern, e.g. registry.terraform.io/hashicorp/kubernetes; for pulumi, the provider
+	// type, e.g. pulumi:providers:kubernetes; for crossplane, the provider API group, e.g. crossplane:gcp.upbound.io
+	SkippedProviders map[string]int `json:"skippedProviders"`
+	// DeletedResources the number of resources in AWS Config that were not reconciled, because
+	// their latest configuration item records that they were deleted
+	DeletedResources int `json:"deletedResources"`
+}
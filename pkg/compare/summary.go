@@ -2,15 +2,30 @@ package compare
 
 import "sort"
 
+// names of the sources in which an item can be found
 const (
-	sourceTerraform = "terraform"
-	sourceConfig    = "config"
+	SourceTerraform      = "terraform"
+	SourceConfig         = "config"
+	SourceCloudFormation = "cloudformation"
+	SourcePulumi         = "pulumi"
+	SourceCrossplane     = "crossplane"
 )
 
 var (
-	SourceKeys = []string{sourceTerraform, sourceConfig}
+	SourceKeys = []string{SourceTerraform, SourceConfig, SourceCloudFormation, SourcePulumi, SourceCrossplane}
 )
 
+// IaCSourceKeys the names of the IaC sources, i.e. every source other than SourceConfig, sorted.
+func IaCSourceKeys() []string {
+	var keys []string
+	for _, key := range SourceKeys {
+		if key != SourceConfig {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 func init() {
 	sort.Strings(SourceKeys)
 }
@@ -18,26 +33,29 @@ func init() {
 // ResourceTypeCount used to keep track of resources that are only in one
 // source or the other. Tracks separate counts for mapped and unmapped.
 type ResourceTypeCount struct {
-	ResourceType string
-	Unmapped     int
-	Mapped       int
+	ResourceType string `json:"resourceType"`
+	Unmapped     int    `json:"unmapped"`
+	Mapped       int    `json:"mapped"`
 }
 
 type SourceSummary struct {
-	Name              string
-	Total             int
-	Only              []ResourceTypeCount
-	OnlyCount         int
-	OnlyMappedCount   int
-	OnlyUnmappedCount int
+	Name              string              `json:"name"`
+	Total             int                 `json:"total"`
+	Only              []ResourceTypeCount `json:"only"`
+	OnlyCount         int                 `json:"onlyCount"`
+	OnlyMappedCount   int                 `json:"onlyMappedCount"`
+	OnlyUnmappedCount int                 `json:"onlyUnmappedCount"`
 }
 
 type TypeSummary struct {
-	ResourceType string
-	Count        int
-	Source       map[string]int
-	SingleOnly   int
-	Both         int
+	ResourceType string         `json:"resourceType"`
+	Count        int            `json:"count"`
+	Source       map[string]int `json:"source"`
+	SingleOnly   int            `json:"singleOnly"`
+	Both         int            `json:"both"`
+	// Accepted count of resources in a single source, that are deliberately left unmanaged;
+	// these are not included in SingleOnly.
+	Accepted int `json:"accepted"`
 }
 
 // Summary struct holding summary information about the various resources.
@@ -45,32 +63,48 @@ type TypeSummary struct {
 type Summary struct {
 	// ByType map by each type, with the values showing how many there
 	// are in each source, total, and both
-	ByType          []TypeSummary
-	Sources         []SourceSummary
-	BothResources   int
-	SingleResources int
+	ByType          []TypeSummary   `json:"byType"`
+	Sources         []SourceSummary `json:"sources"`
+	BothResources   int             `json:"bothResources"`
+	SingleResources int             `json:"singleResources"`
+	// AcceptedResources count of resources in a single source that are deliberately left unmanaged;
+	// these are not included in SingleResources.
+	AcceptedResources int `json:"acceptedResources"`
+	// DuplicateResources count of resources managed by more than one terraform resource
+	DuplicateResources int `json:"duplicateResources"`
 }
 
-// Summarize summarize the information from the reconciliation.
+// Source get the summary for the named source, e.g. SourceConfig.
+func (s *Summary) Source(name string) (SourceSummary, bool) {
+	for _, source := range s.Sources {
+		if source.Name == name {
+			return source, true
+		}
+	}
+	return SourceSummary{}, false
+}
+
+// Summarize summarize the information from the reconciliation. Sources has an entry for
+// every source in SourceKeys, the IaC sources first and SourceConfig last.
 func Summarize(items []*LocatedItem) (results *Summary, err error) {
 	results = &Summary{}
 	var (
-		terraform     = SourceSummary{Name: sourceTerraform}
-		config        = SourceSummary{Name: sourceConfig}
-		only          map[string]*ResourceTypeCount
-		rtc           *ResourceTypeCount
-		configOnly    = make(map[string]*ResourceTypeCount)
-		terraformOnly = make(map[string]*ResourceTypeCount)
-		byType        = make(map[string]*TypeSummary)
+		sources = make(map[string]*SourceSummary)
+		only    = make(map[string]map[string]*ResourceTypeCount)
+		byType  = make(map[string]*TypeSummary)
 	)
+	for _, key := range SourceKeys {
+		sources[key] = &SourceSummary{Name: key}
+		only[key] = make(map[string]*ResourceTypeCount)
+	}
 	// loop through all of the LocatedItems and collate summary info
 	for _, item := range items {
 		// any item which has no ConfigurationItem can be ignored
 		if item.ConfigurationItem == nil {
 			continue
 		}
-		// items that are neither in terraform nor config can be ignored; they are just ephemerally created
-		if !item.config && !item.terraform {
+		// items that are in no source can be ignored; they are just ephemerally created
+		if item.Ephemeral() {
 			continue
 		}
 		// ensure we have a TypeSummary for this type
@@ -83,39 +117,46 @@ func Summarize(items []*LocatedItem) (results *Summary, err error) {
 		ts := byType[item.ResourceType]
 		ts.Count++
 
-		if item.terraform {
-			terraform.Total++
-			if _, ok := ts.Source[sourceTerraform]; !ok {
-				ts.Source[sourceTerraform] = 0
-			}
-			ts.Source[sourceTerraform]++
-			only = terraformOnly
-		}
-		if item.config {
-			config.Total++
-			if _, ok := ts.Source[sourceConfig]; !ok {
-				ts.Source[sourceConfig] = 0
-			}
-			ts.Source[sourceConfig]++
-			only = configOnly
+		if item.Duplicate() {
+			results.DuplicateResources++
 		}
 
-		if _, ok := only[item.ResourceType]; !ok {
-			only[item.ResourceType] = &ResourceTypeCount{
-				ResourceType: item.ResourceType,
+		itemSources := item.Sources()
+		for _, key := range itemSources {
+			sources[key].Total++
+			ts.Source[key]++
+		}
+		// an item in config is only in config, from the point of view of config; otherwise,
+		// it is only in each of its IaC sources
+		onlyIn := itemSources
+		if item.Source(SourceConfig) {
+			onlyIn = []string{SourceConfig}
+		}
+		var rtcs []*ResourceTypeCount
+		for _, key := range onlyIn {
+			if _, ok := only[key][item.ResourceType]; !ok {
+				only[key][item.ResourceType] = &ResourceTypeCount{
+					ResourceType: item.ResourceType,
+				}
 			}
+			rtcs = append(rtcs, only[key][item.ResourceType])
 		}
-		rtc = only[item.ResourceType]
-		if item.config && (item.terraform || item.parent != nil) {
+		switch {
+		case item.Source(SourceConfig) && item.Owned():
 			ts.Both++
 			results.BothResources++
-		} else {
+		case item.Accepted():
+			ts.Accepted++
+			results.AcceptedResources++
+		default:
 			ts.SingleOnly++
 			results.SingleResources++
-			if item.mappedType {
-				rtc.Mapped++
-			} else {
-				rtc.Unmapped++
+			for _, rtc := range rtcs {
+				if item.mappedType {
+					rtc.Mapped++
+				} else {
+					rtc.Unmapped++
+				}
 			}
 		}
 	}
@@ -125,12 +166,11 @@ func Summarize(items []*LocatedItem) (results *Summary, err error) {
 	sort.Slice(results.ByType, func(i, j int) bool {
 		return results.ByType[i].ResourceType < results.ByType[j].ResourceType
 	})
-	// get summary by resource type for unmapped in terraform and unmapped in config
-	processSummaries(&terraform, terraformOnly)
-	results.Sources = append(results.Sources, terraform)
-
-	processSummaries(&config, configOnly)
-	results.Sources = append(results.Sources, config)
+	// get summary by resource type for unmapped in each source
+	for _, key := range append(IaCSourceKeys(), SourceConfig) {
+		processSummaries(sources[key], only[key])
+		results.Sources = append(results.Sources, *sources[key])
+	}
 
 	return results, nil
 }
@@ -0,0 +1,177 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TagPolicy the tags that resources must have, in yaml or json.
+type TagPolicy struct {
+	Required []RequiredTag `yaml:"required" json:"required"`
+	// ResourceTypes globs of the resource types to which the policy applies; all types if empty
+	ResourceTypes []string `yaml:"resourceTypes" json:"resourceTypes"`
+
+	resourceTypes []*regexp.Regexp
+}
+
+// RequiredTag a tag that resources must have.
+type RequiredTag struct {
+	Key string `yaml:"key" json:"key"`
+	// Values globs of the allowed values; any value is allowed if empty
+	Values []string `yaml:"values" json:"values"`
+
+	values []*regexp.Regexp
+}
+
+// TagViolation the required tags that a resource is missing, or whose values are not allowed,
+// in a single source.
+type TagViolation struct {
+	Source  string   `json:"source"`
+	Missing []string `json:"missing,omitempty"`
+	Invalid []string `json:"invalid,omitempty"`
+	// TerraformResource the resource instance whose tags were checked, for SourceTerraform
+	TerraformResource *TerraformResource `json:"terraformResource,omitempty"`
+}
+
+// TagReport the compliance of a single resource with a TagPolicy.
+type TagReport struct {
+	// Team the value of the team tag, from AWS Config, else from terraform
+	Team       string         `json:"team"`
+	Violations []TagViolation `json:"violations,omitempty"`
+	// Mismatched the keys of tags whose value in the terraform tags_all differs from AWS Config,
+	// or that are only on one side, ignoring those reserved by AWS
+	Mismatched []string `json:"mismatched,omitempty"`
+}
+
+// Compliant whether the resource complies with the policy in every source, and its tags match.
+func (t TagReport) Compliant() bool {
+	return len(t.Violations) == 0 && len(t.Mismatched) == 0
+}
+
+// LoadTagPolicy load a tag policy from yaml or json.
+func LoadTagPolicy(r io.Reader) (*TagPolicy, error) {
+	var policy TagPolicy
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to decode tag policy: %w", err)
+	}
+	if len(policy.Required) == 0 {
+		return nil, errors.New("tag policy must require at least one tag")
+	}
+	for _, glob := range policy.ResourceTypes {
+		re, err := globToRegexp(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource type %s: %w", glob, err)
+		}
+		policy.resourceTypes = append(policy.resourceTypes, re)
+	}
+	for i := range policy.Required {
+		required := &policy.Required[i]
+		if required.Key == "" {
+			return nil, fmt.Errorf("required tag %d has no key", i)
+		}
+		for _, glob := range required.Values {
+			re, err := globToRegexp(glob)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %s of required tag %s: %w", glob, required.Key, err)
+			}
+			required.values = append(required.values, re)
+		}
+	}
+	return &policy, nil
+}
+
+// Applies whether the policy applies to the type of the item.
+func (p *TagPolicy) Applies(item *LocatedItem) bool {
+	if len(p.resourceTypes) == 0 {
+		return true
+	}
+	for _, re := range p.resourceTypes {
+		if re.MatchString(item.ResourceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check check the tags of the item in AWS Config, and in each terraform resource instance that
+// manages it directly, against the policy, and compare the terraform tags_all with those in AWS
+// Config. Returns false if the item has tags in neither, e.g. because its type has no tags.
+func (p *TagPolicy) Check(item *LocatedItem, teamTag string) (TagReport, bool) {
+	var (
+		report    TagReport
+		checked   bool
+		hasConfig = item.Source(SourceConfig) && item.Recorded() && item.Tags != nil
+	)
+	if hasConfig {
+		checked = true
+		report.Team = item.Tags[teamTag]
+		if violation := p.check(SourceConfig, item.Tags); violation != nil {
+			report.Violations = append(report.Violations, *violation)
+		}
+	}
+	mismatched := make(map[string]bool)
+	for _, resource := range item.terraformResources {
+		if awsTerraformToConfigTypeMap[resource.Type] != item.ResourceType {
+			continue
+		}
+		tags, ok := terraformTags(resource.attributes)
+		if !ok {
+			continue
+		}
+		checked = true
+		if report.Team == "" {
+			report.Team = tags[teamTag]
+		}
+		if violation := p.check(SourceTerraform, tags); violation != nil {
+			resource := resource
+			violation.TerraformResource = &resource
+			report.Violations = append(report.Violations, *violation)
+		}
+		if hasConfig {
+			for _, key := range diffTags(settableTags(item.Tags), tags) {
+				mismatched[key] = true
+			}
+		}
+	}
+	for key := range mismatched {
+		report.Mismatched = append(report.Mismatched, key)
+	}
+	sort.Strings(report.Mismatched)
+	return report, checked
+}
+
+// check the tags of a single source against the policy; returns nil if they comply
+func (p *TagPolicy) check(source string, tags map[string]string) *TagViolation {
+	violation := TagViolation{Source: source}
+	for _, required := range p.Required {
+		value, ok := tags[required.Key]
+		if !ok {
+			violation.Missing = append(violation.Missing, required.Key)
+			continue
+		}
+		if len(required.values) == 0 {
+			continue
+		}
+		allowed := false
+		for _, re := range required.values {
+			if re.MatchString(value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violation.Invalid = append(violation.Invalid, required.Key)
+		}
+	}
+	if len(violation.Missing) == 0 && len(violation.Invalid) == 0 {
+		return nil
+	}
+	return &violation
+}
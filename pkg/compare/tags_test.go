@@ -0,0 +1,50 @@
+package compare
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+func TestTagPolicyCheckMismatched(t *testing.T) {
+	policy, err := LoadTagPolicy(strings.NewReader("required:\n- key: team\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		config    map[string]string
+		terraform map[string]interface{}
+		want      []string
+	}{
+		{"same", map[string]string{"team": "a"}, map[string]interface{}{"team": "a"}, nil},
+		{"other value", map[string]string{"team": "a"}, map[string]interface{}{"team": "b"}, []string{"team"}},
+		{"only in terraform", map[string]string{"team": "a"}, map[string]interface{}{"team": "a", "env": "prod"}, []string{"env"}},
+		{
+			"reserved tags",
+			map[string]string{"team": "a", "aws:cloudformation:stack-name": "app", "aws:cloudformation:logical-id": "Bucket"},
+			map[string]interface{}{"team": "a"},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := &LocatedItem{
+				ConfigurationItem: &load.ConfigurationItem{ResourceType: "AWS::S3::Bucket", Tags: tt.config},
+				terraformResources: []TerraformResource{
+					{Type: "aws_s3_bucket", Name: "b", attributes: map[string]interface{}{"tags_all": tt.terraform}},
+				},
+			}
+			item.addSource(SourceConfig)
+			report, checked := policy.Check(item, "team")
+			if !checked {
+				t.Fatal("Check() did not check the item")
+			}
+			if !reflect.DeepEqual(report.Mismatched, tt.want) {
+				t.Errorf("Mismatched = %v, want %v", report.Mismatched, tt.want)
+			}
+		})
+	}
+}
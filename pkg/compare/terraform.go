@@ -0,0 +1,42 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TerraformResource identifies the terraform resource instance that matched a LocatedItem.
+type TerraformResource struct {
+	Statefile string      `json:"statefile"`
+	Module    string      `json:"module,omitempty"`
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	IndexKey  interface{} `json:"indexKey,omitempty"`
+	// attributes of the resource instance, to compare with the configuration in AWS Config
+	attributes map[string]interface{}
+}
+
+// Address the terraform address of the resource instance, e.g. module.vpc.aws_subnet.private["a"]
+func (t TerraformResource) Address() string {
+	var parts []string
+	if t.Module != "" {
+		parts = append(parts, t.Module)
+	}
+	parts = append(parts, t.Type+"."+t.Name)
+	address := strings.Join(parts, ".")
+	switch key := t.IndexKey.(type) {
+	case nil:
+	case string:
+		address += fmt.Sprintf("[%q]", key)
+	case float64:
+		address += fmt.Sprintf("[%d]", int64(key))
+	default:
+		address += fmt.Sprintf("[%v]", key)
+	}
+	return address
+}
+
+// String the statefile and address of the resource instance.
+func (t TerraformResource) String() string {
+	return t.Statefile + ":" + t.Address()
+}
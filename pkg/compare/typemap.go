@@ -3,6 +3,8 @@ package compare
 import (
 	_ "embed"
 	"encoding/json"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -17,13 +19,44 @@ var (
 	awsConfigToTerraformTypeMap map[string]string
 )
 
+// preferredTerraformTypes the terraform type of config types to which more than one terraform type
+// is mapped; for any other such config type, the first terraform type in lexical order is used.
+var preferredTerraformTypes = map[string]string{
+	// composite alarms are far less common than metric alarms
+	"AWS::CloudWatch::Alarm": "aws_cloudwatch_metric_alarm",
+}
+
 func init() {
 	awsTerraformToConfigTypeMap = make(map[string]string)
 	awsConfigToTerraformTypeMap = make(map[string]string)
 	if err := json.Unmarshal(awsTerraformToConfigTypeMapJSON, &awsTerraformToConfigTypeMap); err != nil {
 		log.Fatalf("unable to unmarshal typemap.json: %v", err)
 	}
-	for k, v := range awsTerraformToConfigTypeMap {
+	terraformTypes := make([]string, 0, len(awsTerraformToConfigTypeMap))
+	for k := range awsTerraformToConfigTypeMap {
+		terraformTypes = append(terraformTypes, k)
+	}
+	sort.Strings(terraformTypes)
+	for _, k := range terraformTypes {
+		v := awsTerraformToConfigTypeMap[k]
+		if _, ok := awsConfigToTerraformTypeMap[v]; !ok {
+			awsConfigToTerraformTypeMap[v] = k
+		}
+	}
+	for v, k := range preferredTerraformTypes {
 		awsConfigToTerraformTypeMap[v] = k
 	}
 }
+
+// knownConfigType the Config type that matches the given type case-insensitively, among those
+// to which any terraform or pulumi type is mapped.
+func knownConfigType(resourceType string) (string, bool) {
+	for _, m := range []map[string]string{awsTerraformToConfigTypeMap, awsPulumiToConfigTypeMap} {
+		for _, configType := range m {
+			if strings.EqualFold(configType, resourceType) {
+				return configType, true
+			}
+		}
+	}
+	return "", false
+}
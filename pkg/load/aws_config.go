@@ -1,9 +1,26 @@
 package load
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
 const (
 	Regional = "Regional"
 )
 
+// The statuses of configuration items, as configurationItemStatus.
+const (
+	StatusOK                         = "OK"
+	StatusResourceDiscovered         = "ResourceDiscovered"
+	StatusResourceNotRecorded        = "ResourceNotRecorded"
+	StatusResourceDeleted            = "ResourceDeleted"
+	StatusResourceDeletedNotRecorded = "ResourceDeletedNotRecorded"
+)
+
 type Snapshot struct {
 	FileVersion        string              `json:"fileVersion"`
 	ConfigSnapShotID   string              `json:"configSnapshotId"`
@@ -25,11 +42,80 @@ type ConfigurationItem struct {
 	Region                     string                     `json:"awsRegion"` // should be limited to certain regions
 	Zone                       string                     `json:"availabilityZone"`
 	AccountID                  string                     `json:"awsAccountId"`            // should be limited to numeric
-	Status                     string                     `json:"configurationItemStatus"` // should be limited to the limited sets of status
+	Status                     string                     `json:"configurationItemStatus"` // one of the Status constants
+	Version                    string                     `json:"configurationItemVersion"`
+	StateID                    json.Number                `json:"configurationStateId"`
+	CaptureTime                Timestamp                  `json:"configurationItemCaptureTime"`
+	CreationTime               Timestamp                  `json:"resourceCreationTime"`
 	Relationships              []Relationship             `json:"relationships"`
 	Configuration              Configuration              `json:"configuration"`
 	SupplementaryConfiguration SupplementaryConfiguration `json:"supplementaryConfiguration"`
 	Tags                       map[string]string          `json:"tags"`
+	// SourceFile the snapshot file from which the item was read, if any
+	SourceFile string `json:"-"`
+}
+
+// Deleted whether the item records that the resource was deleted.
+func (ci *ConfigurationItem) Deleted() bool {
+	return ci.Status == StatusResourceDeleted || ci.Status == StatusResourceDeletedNotRecorded
+}
+
+// Recorded whether the configuration of the resource was recorded; it is not for types that
+// the recorder excludes, whose items only say that the resource exists, or was deleted.
+func (ci *ConfigurationItem) Recorded() bool {
+	return ci.Status != StatusResourceNotRecorded && ci.Status != StatusResourceDeletedNotRecorded
+}
+
+// Lean a copy of the item without its configuration and supplementary configuration, which are
+// most of its size, e.g. to keep in memory while they are kept elsewhere.
+func (ci *ConfigurationItem) Lean() *ConfigurationItem {
+	lean := *ci
+	lean.Configuration = Configuration{}
+	lean.SupplementaryConfiguration = SupplementaryConfiguration{}
+	return &lean
+}
+
+// Timestamp a time recorded by AWS Config, which is in RFC 3339 in snapshots, and may be seconds
+// since the epoch in the output of the API. The zero value is an unknown time.
+type Timestamp struct {
+	time.Time
+}
+
+// UnmarshalJSON decode an RFC 3339 string, or a number of seconds since the epoch; empty strings
+// and null are the zero time.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if !bytes.HasPrefix(data, []byte(`"`)) {
+		seconds, err := strconv.ParseFloat(string(data), 64)
+		if err != nil {
+			return fmt.Errorf("invalid time %s: %w", data, err)
+		}
+		t.Time = time.Unix(0, int64(seconds*float64(time.Second))).UTC()
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return fmt.Errorf("invalid time %s: %w", s, err)
+	}
+	t.Time = parsed
+	return nil
+}
+
+// MarshalJSON encode as RFC 3339, or null if the time is unknown.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Time)
 }
 
 type Configuration struct {
@@ -51,10 +137,79 @@ type Configuration struct {
 	TargetGroupARNs       []string               `json:"targetGroupARNs,omitempty"`
 	DBClusterIdentifier   string                 `json:"dbclusterIdentifier,omitempty"`
 	LoadBalancerARN       string                 `json:"LoadBalancerARN,omitempty"`
+	InstanceType          string                 `json:"instanceType,omitempty"`
+	ImageID               string                 `json:"imageId,omitempty"`
+	VolumeType            string                 `json:"volumeType,omitempty"`
+	KMSKeyID              string                 `json:"kmsKeyId,omitempty"`
+	Encrypted             *bool                  `json:"encrypted,omitempty"`
+	StorageEncrypted      *bool                  `json:"storageEncrypted,omitempty"`
+	DBInstanceClass       string                 `json:"dBInstanceClass,omitempty"`
+	EngineVersion         string                 `json:"engineVersion,omitempty"`
+	Runtime               string                 `json:"runtime,omitempty"`
+	MemorySize            int64                  `json:"memorySize,omitempty"`
+	// Raw the complete configuration as recorded, of which the fields above are only a few
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decode the fields of the configuration, and keep all of it in Raw.
+func (c *Configuration) UnmarshalJSON(data []byte) error {
+	// an alias has the same fields, but not this method
+	type configuration Configuration
+	var decoded configuration
+	raw, err := unmarshalKeepingRaw(data, &decoded)
+	if err != nil {
+		return err
+	}
+	*c = Configuration(decoded)
+	c.Raw = raw
+	return nil
 }
 
 type SupplementaryConfiguration struct {
 	UnsupportedResources []ResourcePair `json:"unsupportedResources,omitempty"`
+	// Raw the complete supplementary configuration as recorded, e.g. the versioning and
+	// encryption configuration of S3 buckets
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decode the fields of the supplementary configuration, and keep all of it in Raw.
+func (s *SupplementaryConfiguration) UnmarshalJSON(data []byte) error {
+	type supplementaryConfiguration SupplementaryConfiguration
+	var decoded supplementaryConfiguration
+	raw, err := unmarshalKeepingRaw(data, &decoded)
+	if err != nil {
+		return err
+	}
+	*s = SupplementaryConfiguration(decoded)
+	s.Raw = raw
+	return nil
+}
+
+// unmarshalKeepingRaw decode the json into v, and return a compacted copy of it, or nil if it is
+// null or empty. The json may also be a string of json, as returned by some of the AWS Config APIs.
+func unmarshalKeepingRaw(data []byte, v interface{}) (json.RawMessage, error) {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		if s == "" {
+			return nil, nil
+		}
+		data = []byte(s)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, data); err != nil {
+		return nil, err
+	}
+	// a copy of exactly the compacted length, as the buffer may have grown beyond it
+	return append(json.RawMessage(nil), compacted.Bytes()...), nil
 }
 
 type Association struct {
@@ -94,6 +249,7 @@ type IPPermission struct {
 
 type IPV4Range struct {
 	CIDRIP      string `json:"cidrIp"`
+	CIDRIPv6    string `json:"cidrIpv6,omitempty"`
 	Description string `json:"description,omitempty"`
 }
 
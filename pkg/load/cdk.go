@@ -0,0 +1,172 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// cdkManifestFile the manifest of a cloud assembly, i.e. the output directory of cdk synth
+	cdkManifestFile = "manifest.json"
+	// cdkStackArtifact the type of the manifest artifacts that are CloudFormation stacks
+	cdkStackArtifact = "aws:cloudformation:stack"
+	// cdkNestedAssemblyArtifact the type of the manifest artifacts that are nested assemblies, i.e. stages
+	cdkNestedAssemblyArtifact = "cdk:cloud-assembly"
+	// cdkPathMetadata the template metadata key of the construct path of a resource
+	cdkPathMetadata = "aws:cdk:path"
+	// cdkEnvironmentPrefix the prefix of the environment of a stack, aws://<account>/<region>
+	cdkEnvironmentPrefix = "aws://"
+	// cdkUnknown the prefix of the account and region of environment-agnostic stacks
+	cdkUnknown = "unknown-"
+)
+
+// cdkManifest the manifest.json of a cloud assembly
+type cdkManifest struct {
+	Artifacts map[string]cdkArtifact `json:"artifacts"`
+}
+
+type cdkArtifact struct {
+	Type        string `json:"type"`
+	Environment string `json:"environment"`
+	Properties  struct {
+		TemplateFile  string `json:"templateFile"`
+		StackName     string `json:"stackName"`
+		DirectoryName string `json:"directoryName"`
+	} `json:"properties"`
+}
+
+// cdkTemplate the parts of a synthesized CloudFormation template that are needed to resolve its resources
+type cdkTemplate struct {
+	Resources map[string]struct {
+		Type     string                 `json:"Type"`
+		Metadata map[string]interface{} `json:"Metadata"`
+	} `json:"Resources"`
+}
+
+// ResolveCDKAssembly resolve the resources of the templates of every stack in the cloud assembly in dir,
+// e.g. cdk.out, to their physical IDs in the exported stack resources, as returned by LoadStackResources.
+// The resources of the assembly's stacks are replaced by those of the templates, with the construct
+// path and the environment of the stack; the resources of all other stacks are returned unchanged.
+// Template resources that are not in the export, e.g. because the stack was not deployed since they
+// were added, are omitted.
+func ResolveCDKAssembly(dir string, exported []StackResource) ([]StackResource, error) {
+	stacks := make(map[string][]StackResource)
+	if err := loadCDKAssembly(dir, stacks); err != nil {
+		return nil, err
+	}
+	// physical IDs of the export, by stack and logical ID
+	deployed := make(map[string]map[string]StackResource)
+	var resources []StackResource
+	for _, resource := range exported {
+		if _, ok := stacks[resource.StackName]; !ok {
+			resources = append(resources, resource)
+			continue
+		}
+		if deployed[resource.StackName] == nil {
+			deployed[resource.StackName] = make(map[string]StackResource)
+		}
+		deployed[resource.StackName][resource.LogicalResourceID] = resource
+	}
+	names := make([]string, 0, len(stacks))
+	for name := range stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if deployed[name] == nil {
+			log.Debugf("cdk stack %s is not in any stack resources export", name)
+			continue
+		}
+		for _, resource := range stacks[name] {
+			d, ok := deployed[name][resource.LogicalResourceID]
+			if !ok {
+				log.Debugf("cdk resource %s/%s is not deployed", name, resource.LogicalResourceID)
+				continue
+			}
+			resource.StackID = d.StackID
+			resource.PhysicalResourceID = d.PhysicalResourceID
+			resource.ResourceStatus = d.ResourceStatus
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+// loadCDKAssembly read the templates of the stacks of the cloud assembly in dir, and of all of its
+// nested assemblies, into stacks, keyed by stack name. Resources are sorted by logical ID.
+func loadCDKAssembly(dir string, stacks map[string][]StackResource) error {
+	var manifest cdkManifest
+	if err := readJSONFile(filepath.Join(dir, cdkManifestFile), &manifest); err != nil {
+		return fmt.Errorf("unable to read cdk manifest: %w", err)
+	}
+	for id, artifact := range manifest.Artifacts {
+		switch artifact.Type {
+		case cdkNestedAssemblyArtifact:
+			if err := loadCDKAssembly(filepath.Join(dir, artifact.Properties.DirectoryName), stacks); err != nil {
+				return err
+			}
+		case cdkStackArtifact:
+			stackName := artifact.Properties.StackName
+			if stackName == "" {
+				stackName = id
+			}
+			if _, ok := stacks[stackName]; ok {
+				return fmt.Errorf("duplicate cdk stack %s in %s", stackName, dir)
+			}
+			var template cdkTemplate
+			if err := readJSONFile(filepath.Join(dir, artifact.Properties.TemplateFile), &template); err != nil {
+				return fmt.Errorf("unable to read template of cdk stack %s: %w", stackName, err)
+			}
+			account, region := parseCDKEnvironment(artifact.Environment)
+			resources := make([]StackResource, 0, len(template.Resources))
+			for logicalID, r := range template.Resources {
+				path, _ := r.Metadata[cdkPathMetadata].(string)
+				resources = append(resources, StackResource{
+					StackName:         stackName,
+					LogicalResourceID: logicalID,
+					ResourceType:      r.Type,
+					ConstructPath:     path,
+					AccountID:         account,
+					Region:            region,
+				})
+			}
+			sort.Slice(resources, func(i, j int) bool {
+				return resources[i].LogicalResourceID < resources[j].LogicalResourceID
+			})
+			stacks[stackName] = resources
+		}
+	}
+	return nil
+}
+
+// parseCDKEnvironment get the account and region of a stack environment, aws://<account>/<region>,
+// either of which is empty if the stack is environment-agnostic.
+func parseCDKEnvironment(environment string) (account, region string) {
+	account, region, _ = strings.Cut(strings.TrimPrefix(environment, cdkEnvironmentPrefix), "/")
+	if strings.HasPrefix(account, cdkUnknown) {
+		account = ""
+	}
+	if strings.HasPrefix(region, cdkUnknown) {
+		region = ""
+	}
+	return account, region
+}
+
+// readJSONFile decode the json file into v
+func readJSONFile(file string, v interface{}) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("unable to decode %s: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// stackStatusDeleteComplete the status of a stack resource that has been deleted
+	stackStatusDeleteComplete = "DELETE_COMPLETE"
+)
+
+// StackResource a resource of a CloudFormation stack, as returned by
+// DescribeStackResources or ListStackResources.
+type StackResource struct {
+	StackName          string `json:"StackName"`
+	StackID            string `json:"StackId"`
+	LogicalResourceID  string `json:"LogicalResourceId"`
+	PhysicalResourceID string `json:"PhysicalResourceId"`
+	ResourceType       string `json:"ResourceType"`
+	ResourceStatus     string `json:"ResourceStatus"`
+	// ConstructPath the path of the construct of the resource, for stacks synthesized by the CDK
+	ConstructPath string `json:"-"`
+	// AccountID and Region the environment of stacks synthesized by the CDK, when not environment-agnostic
+	AccountID string `json:"-"`
+	Region    string `json:"-"`
+}
+
+// stackResourcesExport the output of aws cloudformation describe-stack-resources or
+// list-stack-resources; the latter does not include the stack, so it is passed separately.
+type stackResourcesExport struct {
+	StackResources         []StackResource `json:"StackResources"`
+	StackResourceSummaries []StackResource `json:"StackResourceSummaries"`
+	StackName              string          `json:"StackName"`
+	StackID                string          `json:"StackId"`
+}
+
+// LoadStackResources read the CloudFormation stack resources exported to the files, each of which
+// is the json output of aws cloudformation describe-stack-resources or list-stack-resources.
+// Resources that were deleted, or that were never created, are omitted.
+func LoadStackResources(files []string) ([]StackResource, error) {
+	var resources []StackResource
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open stack resources file %s: %w", file, err)
+		}
+		var export stackResourcesExport
+		err = json.NewDecoder(f).Decode(&export)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode stack resources file %s: %w", file, err)
+		}
+		for _, resource := range append(export.StackResources, export.StackResourceSummaries...) {
+			if resource.PhysicalResourceID == "" || resource.ResourceStatus == stackStatusDeleteComplete {
+				continue
+			}
+			if resource.StackName == "" {
+				resource.StackName = export.StackName
+			}
+			if resource.StackID == "" {
+				resource.StackID = export.StackID
+			}
+			if resource.StackName == "" {
+				// the name of the file is the best we have, e.g. <stack>.json
+				resource.StackName = strings.TrimSuffix(file, ".json")
+			}
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,55 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// CrossplaneResource a crossplane managed resource, as returned by kubectl get managed -o json.
+type CrossplaneResource struct {
+	// APIVersion <group>/<version>, e.g. ec2.aws.upbound.io/v1beta1
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+		// DeletionTimestamp set when the resource is being deleted
+		DeletionTimestamp string `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		ForProvider map[string]interface{} `json:"forProvider"`
+		// ManagementPolicies the actions crossplane may take, e.g. ["Observe"] for a resource it only reads
+		ManagementPolicies []string `json:"managementPolicies"`
+		// ManagementPolicy the former, single management policy, e.g. ObserveOnly
+		ManagementPolicy string `json:"managementPolicy"`
+	} `json:"spec"`
+	Status struct {
+		AtProvider map[string]interface{} `json:"atProvider"`
+	} `json:"status"`
+}
+
+// crossplaneList the output of kubectl get -o json, a list of resources
+type crossplaneList struct {
+	Items []CrossplaneResource `json:"items"`
+}
+
+// LoadCrossplaneResources read the crossplane managed resources dumped to the files, each of which
+// is the output of kubectl get managed -o json.
+func LoadCrossplaneResources(files []string) ([]CrossplaneResource, error) {
+	var resources []CrossplaneResource
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open crossplane resources file %s: %w", file, err)
+		}
+		var list crossplaneList
+		err = json.NewDecoder(f).Decode(&list)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode crossplane resources file %s: %w", file, err)
+		}
+		resources = append(resources, list.Items...)
+	}
+	return resources, nil
+}
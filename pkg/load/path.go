@@ -0,0 +1,287 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Path a path to attributes of a configuration item, in a subset of JSONPath, e.g.
+// configuration.instanceType, supplementaryConfiguration.BucketVersioningConfiguration.status,
+// configuration.ipPermissions[*].ipv4Ranges[0].cidrIp, or tags["aws:cloudformation:stack-name"].
+// The leading $. is optional. Values that are strings of json, as AWS Config records some of
+// the supplementary configuration, are decoded as they are traversed.
+type Path struct {
+	source   string
+	segments []pathSegment
+}
+
+// pathSegment a single step of a path: the key of an object, the index of a list, or every
+// element of either
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParsePath parse a path to attributes of a configuration item.
+func ParsePath(s string) (Path, error) {
+	path := Path{source: s}
+	rest := strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	if rest == "" {
+		return path, fmt.Errorf("invalid path %q: empty", s)
+	}
+	for first := true; rest != ""; first = false {
+		switch {
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return path, fmt.Errorf("invalid path %q: unclosed [", s)
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				path.segments = append(path.segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				path.segments = append(path.segments, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return path, fmt.Errorf("invalid path %q: invalid index %q", s, inner)
+				}
+				path.segments = append(path.segments, pathSegment{index: index, isIndex: true})
+			}
+			rest = rest[end+1:]
+		case rest[0] == '.' || first:
+			if !first {
+				rest = rest[1:]
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return path, fmt.Errorf("invalid path %q: empty key", s)
+			}
+			if key == "*" {
+				path.segments = append(path.segments, pathSegment{wildcard: true})
+			} else {
+				path.segments = append(path.segments, pathSegment{key: key})
+			}
+			rest = rest[end:]
+		default:
+			return path, fmt.Errorf("invalid path %q: expected . or [ at %q", s, rest)
+		}
+	}
+	return path, nil
+}
+
+// MustParsePath parse a path that is known to be valid, panicking otherwise.
+func MustParsePath(s string) Path {
+	path, err := ParsePath(s)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// String the path as it was parsed
+func (p Path) String() string {
+	return p.source
+}
+
+// Lookup the values at the path in a decoded json value, e.g. from json.Unmarshal into an
+// interface{}. Returns nothing if the path does not exist, and more than one value if it has
+// wildcards.
+func (p Path) Lookup(v interface{}) []interface{} {
+	values := []interface{}{v}
+	for _, segment := range p.segments {
+		var next []interface{}
+		for _, value := range values {
+			value = decodeJSONString(value)
+			switch {
+			case segment.wildcard:
+				switch container := value.(type) {
+				case []interface{}:
+					next = append(next, container...)
+				case map[string]interface{}:
+					for _, key := range sortedKeys(container) {
+						next = append(next, container[key])
+					}
+				}
+			case segment.isIndex:
+				if list, ok := value.([]interface{}); ok && segment.index < len(list) {
+					next = append(next, list[segment.index])
+				}
+			default:
+				if object, ok := value.(map[string]interface{}); ok {
+					if child, ok := object[segment.key]; ok {
+						next = append(next, child)
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// LookupString the values at the path in a decoded json value, as strings: scalars as they are,
+// anything else as json. Nulls are skipped.
+func (p Path) LookupString(v interface{}) []string {
+	var values []string
+	for _, value := range p.Lookup(v) {
+		if s, ok := PathValueString(value); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// PathValueString a value found by a Path as a string: scalars as they are, lists and objects
+// as json. Returns false for null.
+func PathValueString(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	case bool:
+		return strconv.FormatBool(value), true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+}
+
+// Lookup the values at the path in the configuration item. The path starts with the name of a
+// field of the item as recorded by AWS Config, e.g. configuration, supplementaryConfiguration,
+// tags, relationships, resourceId or awsRegion. Returns an error if the recorded configuration
+// cannot be decoded.
+func (ci *ConfigurationItem) Lookup(p Path) ([]interface{}, error) {
+	if len(p.segments) == 0 {
+		return nil, nil
+	}
+	first := p.segments[0]
+	root := make(map[string]interface{})
+	switch first.key {
+	case "configuration":
+		value, err := decodeRaw(ci.Configuration.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode configuration of %s %s: %w", ci.ResourceType, ci.ResourceID, err)
+		}
+		root[first.key] = value
+	case "supplementaryConfiguration":
+		value, err := decodeRaw(ci.SupplementaryConfiguration.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode supplementary configuration of %s %s: %w", ci.ResourceType, ci.ResourceID, err)
+		}
+		root[first.key] = value
+	case "tags":
+		tags := make(map[string]interface{}, len(ci.Tags))
+		for k, v := range ci.Tags {
+			tags[k] = v
+		}
+		root[first.key] = tags
+	case "relationships":
+		relationships := make([]interface{}, 0, len(ci.Relationships))
+		for _, r := range ci.Relationships {
+			relationships = append(relationships, map[string]interface{}{
+				"resourceType": r.ResourceType,
+				"resourceId":   r.ResourceID,
+				"resourceName": r.ResourceName,
+				"name":         r.Name,
+			})
+		}
+		root[first.key] = relationships
+	default:
+		for key, value := range map[string]string{
+			"resourceType":             ci.ResourceType,
+			"resourceId":               ci.ResourceID,
+			"resourceName":             ci.ResourceName,
+			"ARN":                      ci.ARN,
+			"awsRegion":                ci.Region,
+			"availabilityZone":         ci.Zone,
+			"awsAccountId":             ci.AccountID,
+			"configurationItemStatus":  ci.Status,
+			"configurationItemVersion": ci.Version,
+			"configurationStateId":     ci.StateID.String(),
+		} {
+			if value != "" {
+				root[key] = value
+			}
+		}
+		for key, value := range map[string]Timestamp{
+			"configurationItemCaptureTime": ci.CaptureTime,
+			"resourceCreationTime":         ci.CreationTime,
+		} {
+			if !value.IsZero() {
+				root[key] = value.Format(time.RFC3339)
+			}
+		}
+	}
+	return p.Lookup(root), nil
+}
+
+// LookupString the values at the path in the configuration item, as strings, as for
+// Path.LookupString.
+func (ci *ConfigurationItem) LookupString(p Path) ([]string, error) {
+	values, err := ci.Lookup(p)
+	if err != nil {
+		return nil, err
+	}
+	var strs []string
+	for _, value := range values {
+		if s, ok := PathValueString(value); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs, nil
+}
+
+func decodeRaw(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeJSONString decode a string that is a json object or list, or return the value as it is
+func decodeJSONString(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) < 2 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return v
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(trimmed), &decoded); err != nil {
+		return v
+	}
+	return decoded
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
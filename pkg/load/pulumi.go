@@ -0,0 +1,54 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// PulumiStackExport the output of pulumi stack export.
+type PulumiStackExport struct {
+	Version    int `json:"version"`
+	Deployment struct {
+		Resources []PulumiResource `json:"resources"`
+	} `json:"deployment"`
+}
+
+// PulumiResource a single resource of a pulumi stack.
+type PulumiResource struct {
+	// URN urn:pulumi:<stack>::<project>::<qualified type>::<name>
+	URN  string `json:"urn"`
+	Type string `json:"type"`
+	// Custom whether it is a resource managed by a provider, rather than a component
+	Custom bool `json:"custom"`
+	// Delete whether it is pending deletion, after a replacement
+	Delete bool `json:"delete"`
+	// External whether it is only read by pulumi, rather than managed
+	External bool                   `json:"external"`
+	ID       string                 `json:"id"`
+	Inputs   map[string]interface{} `json:"inputs"`
+	Outputs  map[string]interface{} `json:"outputs"`
+	Parent   string                 `json:"parent"`
+	// Provider reference to the provider resource, as <urn>::<id>
+	Provider string `json:"provider"`
+}
+
+// LoadPulumiStacks read the resources of the stacks exported to the files, each of which
+// is the output of pulumi stack export.
+func LoadPulumiStacks(files []string) ([]PulumiResource, error) {
+	var resources []PulumiResource
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open pulumi stack file %s: %w", file, err)
+		}
+		var export PulumiStackExport
+		err = json.NewDecoder(f).Decode(&export)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode pulumi stack file %s: %w", file, err)
+		}
+		resources = append(resources, export.Deployment.Resources...)
+	}
+	return resources, nil
+}
@@ -0,0 +1,255 @@
+package load
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// snapshotDir directory in the AWS Config delivery layout that contains snapshots,
+	// as opposed to ConfigHistory
+	snapshotDir = "ConfigSnapshot"
+	// configDir directory in the AWS Config delivery layout, between account and region
+	configDir = "Config"
+)
+
+// snapshotTimestamp the timestamp in the name of a delivered snapshot, e.g.
+// 123456789012_Config_us-east-1_ConfigSnapshot_20231015T120000Z_<uuid>.json.gz
+var snapshotTimestamp = regexp.MustCompile(`_(\d{8}T\d{6}Z)_`)
+
+// SnapshotOption an option for reading snapshots.
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	discardRaw bool
+}
+
+// WithoutRawConfiguration discard the raw configuration and supplementary configuration of each
+// item as soon as it is decoded, keeping only the typed fields, which reduces memory for very
+// large snapshots. Paths can then only reach the fields of the item outside the configuration.
+func WithoutRawConfiguration() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.discardRaw = true
+	}
+}
+
+// LoadSnapshots read and merge the AWS Config snapshots at the given paths. Each path is
+// either a snapshot file, optionally gzipped, or a directory mirroring the AWS Config
+// delivery layout, i.e. AWSLogs/<account>/Config/<region>/YYYY/M/D/ConfigSnapshot/.
+// For a directory, only the latest snapshot of each account and region is used.
+// Every ConfigurationItem has its SourceFile set to the file from which it was read.
+func LoadSnapshots(paths []string, opts ...SnapshotOption) (Snapshot, error) {
+	var items []ConfigurationItem
+	snapshot, err := StreamSnapshots(paths, func(item ConfigurationItem) error {
+		items = append(items, item)
+		return nil
+	}, opts...)
+	snapshot.ConfigurationItems = items
+	return snapshot, err
+}
+
+// StreamSnapshots read the same snapshots as LoadSnapshots, calling fn with each item in turn as
+// it is decoded, so that they need not all be held at once. The returned snapshot has the file
+// version and ID of the first, and no ConfigurationItems.
+func StreamSnapshots(paths []string, fn func(item ConfigurationItem) error, opts ...SnapshotOption) (Snapshot, error) {
+	var (
+		snapshot Snapshot
+		files    []string
+	)
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return snapshot, fmt.Errorf("unable to read snapshot path %s: %w", p, err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		dirFiles, err := findSnapshotFiles(p)
+		if err != nil {
+			return snapshot, fmt.Errorf("unable to search snapshot directory %s: %w", p, err)
+		}
+		files = append(files, dirFiles...)
+	}
+	for _, file := range files {
+		s, err := streamSnapshotFile(file, opts, fn)
+		if err != nil {
+			return snapshot, err
+		}
+		if snapshot.FileVersion == "" {
+			snapshot.FileVersion = s.FileVersion
+			snapshot.ConfigSnapShotID = s.ConfigSnapShotID
+		}
+	}
+	return snapshot, nil
+}
+
+// ReadSnapshotFile read a single snapshot file, which may be gzipped.
+func ReadSnapshotFile(file string, opts ...SnapshotOption) (Snapshot, error) {
+	var items []ConfigurationItem
+	snapshot, err := streamSnapshotFile(file, opts, func(item ConfigurationItem) error {
+		items = append(items, item)
+		return nil
+	})
+	snapshot.ConfigurationItems = items
+	return snapshot, err
+}
+
+// streamSnapshotFile decode a single snapshot file, which may be gzipped, calling fn with each
+// item, with its SourceFile set.
+func streamSnapshotFile(file string, opts []SnapshotOption, fn func(item ConfigurationItem) error) (Snapshot, error) {
+	var o snapshotOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("unable to open snapshot file %s: %w", file, err)
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("unable to decompress snapshot file %s: %w", file, err)
+	}
+	snapshot, err := DecodeSnapshot(r, func(item ConfigurationItem) error {
+		item.SourceFile = file
+		if o.discardRaw {
+			item.Configuration.Raw = nil
+			item.SupplementaryConfiguration.Raw = nil
+		}
+		return fn(item)
+	})
+	if err != nil {
+		return snapshot, fmt.Errorf("unable to decode snapshot file %s: %w", file, err)
+	}
+	return snapshot, nil
+}
+
+// DecodeSnapshot decode a snapshot, calling fn with each of its configuration items in turn as
+// it is decoded, rather than decoding all of them at once, so that only a single item of the
+// input is held in memory at a time. The returned snapshot has no ConfigurationItems.
+func DecodeSnapshot(r io.Reader, fn func(item ConfigurationItem) error) (Snapshot, error) {
+	var (
+		snapshot Snapshot
+		dec      = json.NewDecoder(r)
+	)
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "fileVersion":
+			return dec.Decode(&snapshot.FileVersion)
+		case "configSnapshotId":
+			return dec.Decode(&snapshot.ConfigSnapShotID)
+		case "configurationItems":
+			return decodeArray(dec, func() error {
+				// a new item each time, as decoding into the same one would share its slices and maps
+				var item ConfigurationItem
+				if err := dec.Decode(&item); err != nil {
+					return err
+				}
+				return fn(item)
+			})
+		default:
+			return skipValue(dec)
+		}
+	})
+	return snapshot, err
+}
+
+// decompress return a reader of the uncompressed content, whether or not it is gzipped,
+// based on the content rather than the file name.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// findSnapshotFiles find the snapshot files in a directory. If the directory follows the
+// AWS Config delivery layout, return the latest for each account and region; otherwise,
+// return every .json and .json.gz file.
+func findSnapshotFiles(dir string) ([]string, error) {
+	var (
+		latest    = make(map[string]string)
+		latestKey = make(map[string]string)
+		order     []string
+	)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !(strings.HasSuffix(p, ".json") || strings.HasSuffix(p, ".json.gz")) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		group, isDelivery := deliveryGroup(parts)
+		if !isDelivery {
+			// not the delivery layout, so each file is its own group
+			if hasPart(parts, "ConfigHistory") {
+				return nil
+			}
+			group = p
+		}
+		// the timestamp in the name is the most reliable ordering, as the
+		// date directories are not zero-padded
+		key := p
+		if m := snapshotTimestamp.FindStringSubmatch(filepath.Base(p)); m != nil {
+			key = m[1]
+		}
+		if existing, ok := latestKey[group]; !ok || key > existing {
+			if !ok {
+				order = append(order, group)
+			}
+			latest[group] = p
+			latestKey[group] = key
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, group := range order {
+		files = append(files, latest[group])
+	}
+	return files, nil
+}
+
+// deliveryGroup if the path parts follow the AWS Config delivery layout for snapshots,
+// return <account>/<region>.
+func deliveryGroup(parts []string) (string, bool) {
+	if !hasPart(parts, snapshotDir) {
+		return "", false
+	}
+	for i := 1; i < len(parts)-1; i++ {
+		if parts[i] == configDir {
+			return parts[i-1] + "/" + parts[i+1], true
+		}
+	}
+	return "", false
+}
+
+func hasPart(parts []string, part string) bool {
+	for _, p := range parts {
+		if p == part {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package load
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+	// emptyPayloadHash the sha256 of an empty body, as sent for every GET
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3StateSource terraform states in an S3 bucket, as written by the s3 backend, or in any
+// S3-compatible storage, e.g. MinIO. Every object under Prefix whose key ends in .tfstate is
+// loaded, which includes the states of all workspaces. Each state is named s3://<bucket>/<key>.
+type S3StateSource struct {
+	// Bucket the name of the bucket
+	Bucket string
+	// Prefix only load objects whose keys start with Prefix; optional
+	Prefix string
+	// Region of the bucket, used for signing; defaults to us-east-1
+	Region string
+	// Endpoint URL of the S3 API; defaults to https://s3.<region>.amazonaws.com
+	Endpoint string
+	// PathStyle address the bucket as <endpoint>/<bucket> rather than <bucket>.<endpoint host>,
+	// as most S3-compatible storage requires
+	PathStyle bool
+	// AccessKeyID, SecretAccessKey and SessionToken the credentials with which to sign requests;
+	// if AccessKeyID is empty, requests are not signed
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client the HTTP client; defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// listBucketResult the response of ListObjectsV2
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+func (s S3StateSource) Load(ctx context.Context, states map[string]TerraformState) error {
+	keys, err := s.list(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list s3://%s/%s: %w", s.Bucket, s.Prefix, err)
+	}
+	for _, key := range keys {
+		name := fmt.Sprintf("s3://%s/%s", s.Bucket, key)
+		state, err := s.get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("unable to read terraform state %s: %w", name, err)
+		}
+		if err := addState(states, name, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// list list the keys of all state files under the prefix
+func (s S3StateSource) list(ctx context.Context) ([]string, error) {
+	var (
+		keys  []string
+		token string
+	)
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.Prefix != "" {
+			query.Set("prefix", s.Prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		body, err := s.do(ctx, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(body).Decode(&result)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode object list: %w", err)
+		}
+		for _, object := range result.Contents {
+			if strings.HasSuffix(object.Key, ".tfstate") {
+				keys = append(keys, object.Key)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// get get and decode a single state
+func (s S3StateSource) get(ctx context.Context, key string) (TerraformState, error) {
+	body, err := s.do(ctx, key, nil)
+	if err != nil {
+		return TerraformState{}, err
+	}
+	defer body.Close()
+	return DecodeTerraformState(body)
+}
+
+// do send a signed GET request for the key in the bucket, or for the bucket itself if key is empty
+func (s S3StateSource) do(ctx context.Context, key string, query url.Values) (io.ReadCloser, error) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %w", endpoint, err)
+	}
+	objectPath := "/" + key
+	if s.PathStyle {
+		objectPath = "/" + s.Bucket + objectPath
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.AccessKeyID != "" {
+		s.sign(req, region, time.Now().UTC())
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("GET %s: %s: %s", u.Redacted(), res.Status, strings.TrimSpace(string(msg)))
+	}
+	return res.Body, nil
+}
+
+// sign sign the request with AWS Signature Version 4
+func (s S3StateSource) sign(req *http.Request, region string, now time.Time) {
+	amzDate := now.Format(sigV4TimeFormat)
+	date := now.Format(sigV4DateFormat)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery the query string sorted by key, with keys and values encoded as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encode everything other than unreserved characters, and / unless encodeSlash
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,127 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+const (
+	// workspaceDir directory in which terraform keeps the states of non-default local workspaces
+	workspaceDir = "terraform.tfstate.d"
+	// defaultStateFile the name of the state file of a local workspace
+	defaultStateFile = "terraform.tfstate"
+)
+
+// StateSource a source of terraform states, e.g. local files, an S3 backend or Terraform Cloud.
+type StateSource interface {
+	// Load load every state from the source into states, keyed by a name that identifies the
+	// state within all sources, e.g. its path or URL. Returns an error if a name already exists.
+	Load(ctx context.Context, states map[string]TerraformState) error
+}
+
+// addState add a state to the map, unless one of the same name already exists.
+func addState(states map[string]TerraformState, name string, state TerraformState) error {
+	if _, ok := states[name]; ok {
+		return fmt.Errorf("duplicate terraform state %s", name)
+	}
+	states[name] = state
+	return nil
+}
+
+// LocalStateSource terraform state files on the local filesystem. Each state is named
+// by its path relative to Path, or by its file name if Path is a file.
+type LocalStateSource struct {
+	// Path to a state file, or to a directory if Recursive
+	Path string
+	// Recursive search Path for all .tfstate files
+	Recursive bool
+}
+
+func (l LocalStateSource) Load(ctx context.Context, states map[string]TerraformState) error {
+	var (
+		files []string
+		fsys  fs.FS
+	)
+	if l.Recursive {
+		fsys = os.DirFS(l.Path)
+		if err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if filepath.Ext(path) == ".tfstate" {
+				files = append(files, path)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	} else {
+		fsys = os.DirFS(path.Dir(l.Path))
+		files = []string{path.Base(l.Path)}
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := loadStateFile(fsys, file, file, states); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WorkspaceStateSource the states of all of the local workspaces of a terraform configuration,
+// i.e. terraform.tfstate for the default workspace, and terraform.tfstate.d/<workspace>/terraform.tfstate
+// for the others. Each state is named by its path, i.e. Dir joined with its path within Dir, so that
+// the states of several configurations do not collide.
+type WorkspaceStateSource struct {
+	// Dir the terraform configuration directory
+	Dir string
+}
+
+func (w WorkspaceStateSource) Load(ctx context.Context, states map[string]TerraformState) error {
+	fsys := os.DirFS(w.Dir)
+	files := []string{defaultStateFile}
+	workspaces, err := fs.ReadDir(fsys, workspaceDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read workspaces in %s: %w", w.Dir, err)
+	}
+	for _, workspace := range workspaces {
+		if workspace.IsDir() {
+			files = append(files, path.Join(workspaceDir, workspace.Name(), defaultStateFile))
+		}
+	}
+	var found bool
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// a workspace that has never been applied has no state
+		if _, err := fs.Stat(fsys, file); os.IsNotExist(err) {
+			continue
+		}
+		if err := loadStateFile(fsys, file, filepath.Join(w.Dir, filepath.FromSlash(file)), states); err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no workspace states found in %s", w.Dir)
+	}
+	return nil
+}
+
+// loadStateFile load a single state file from fsys, under the given name
+func loadStateFile(fsys fs.FS, file, name string, states map[string]TerraformState) error {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return fmt.Errorf("unable to open tfstate file %s: %w", file, err)
+	}
+	defer f.Close()
+	state, err := DecodeTerraformState(f)
+	if err != nil {
+		return fmt.Errorf("unable to decode terraform state file %s: %w", file, err)
+	}
+	return addState(states, name, state)
+}
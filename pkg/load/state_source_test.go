@@ -0,0 +1,115 @@
+package load
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testState = `{"version": 4, "terraform_version": "1.5.0", "resources": []}`
+
+// writeFiles write the files, by slash-separated path within dir, creating their directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// stateNames the names of the states, sorted
+func stateNames(states map[string]TerraformState) []string {
+	var names []string
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestLocalStateSourceLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"network.tfstate":          testState,
+		"app/terraform.tfstate":    testState,
+		"app/README.md":            "not a state",
+		"app/.terraform/x.tfstate": testState,
+	})
+	tests := []struct {
+		name   string
+		source LocalStateSource
+		want   []string
+	}{
+		{"file", LocalStateSource{Path: filepath.Join(dir, "network.tfstate")}, []string{"network.tfstate"}},
+		// paths within the directory are slash-separated, as in fs.FS
+		{"recursive", LocalStateSource{Path: dir, Recursive: true}, []string{"app/.terraform/x.tfstate", "app/terraform.tfstate", "network.tfstate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := make(map[string]TerraformState)
+			if err := tt.source.Load(context.Background(), states); err != nil {
+				t.Fatal(err)
+			}
+			if got := stateNames(states); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("states = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		writeFiles(t, dir, map[string]string{"broken.tfstate": "{"})
+		err := LocalStateSource{Path: filepath.Join(dir, "broken.tfstate")}.Load(context.Background(), make(map[string]TerraformState))
+		if err == nil || !strings.Contains(err.Error(), "broken.tfstate") {
+			t.Errorf("Load() = %v, want an error naming the file", err)
+		}
+	})
+}
+
+func TestWorkspaceStateSourceLoad(t *testing.T) {
+	root := t.TempDir()
+	a, b, empty := filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "empty")
+	writeFiles(t, a, map[string]string{
+		"terraform.tfstate":                         testState,
+		"terraform.tfstate.d/dev/terraform.tfstate": testState,
+		// a workspace that was never applied
+		"terraform.tfstate.d/staging/.keep": "",
+	})
+	writeFiles(t, b, map[string]string{"terraform.tfstate": testState})
+	if err := os.Mkdir(empty, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// several configurations, as with --tf-workspaces repeated, each with terraform.tfstate
+	states := make(map[string]TerraformState)
+	for _, dir := range []string{a, b} {
+		if err := (WorkspaceStateSource{Dir: dir}).Load(context.Background(), states); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		filepath.Join(a, "terraform.tfstate"),
+		filepath.Join(a, "terraform.tfstate.d", "dev", "terraform.tfstate"),
+		filepath.Join(b, "terraform.tfstate"),
+	}
+	sort.Strings(want)
+	if got := stateNames(states); !reflect.DeepEqual(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+
+	// the same configuration twice is a duplicate
+	if err := (WorkspaceStateSource{Dir: b}).Load(context.Background(), states); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Load() of the same dir again = %v, want a duplicate error", err)
+	}
+	if err := (WorkspaceStateSource{Dir: empty}).Load(context.Background(), make(map[string]TerraformState)); err == nil {
+		t.Error("Load() of a dir without states returned no error")
+	}
+}
@@ -0,0 +1,194 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultTFCAddress the address of Terraform Cloud
+	DefaultTFCAddress = "https://app.terraform.io"
+	tfcPageSize       = 100
+	tfcContentType    = "application/vnd.api+json"
+)
+
+// TFCStateSource the current states of workspaces in Terraform Cloud or Terraform Enterprise,
+// via the state versions API. Each state is named tfc://<organization>/<workspace>.
+type TFCStateSource struct {
+	// Address of Terraform Cloud or Enterprise; defaults to DefaultTFCAddress
+	Address string
+	// Token an API token with permission to read state versions
+	Token string
+	// Organization the organization of the workspaces
+	Organization string
+	// Workspaces names of the workspaces to load; if empty, all workspaces in the organization
+	Workspaces []string
+	// Client the HTTP client; defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// tfcWorkspaces a page of the response of the list workspaces API
+type tfcWorkspaces struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Name string `json:"name"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Meta struct {
+		Pagination struct {
+			NextPage *int `json:"next-page"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+// tfcStateVersion the response of the current state version API
+type tfcStateVersion struct {
+	Data struct {
+		Attributes struct {
+			HostedStateDownloadURL string `json:"hosted-state-download-url"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+func (t TFCStateSource) Load(ctx context.Context, states map[string]TerraformState) error {
+	workspaces, err := t.workspaces(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list workspaces of %s: %w", t.Organization, err)
+	}
+	wanted := make(map[string]bool)
+	for _, name := range t.Workspaces {
+		wanted[name] = true
+	}
+	for name, id := range workspaces {
+		if len(t.Workspaces) > 0 && !wanted[name] {
+			continue
+		}
+		delete(wanted, name)
+		stateName := fmt.Sprintf("tfc://%s/%s", t.Organization, name)
+		state, ok, err := t.currentState(ctx, id)
+		if err != nil {
+			return fmt.Errorf("unable to read terraform state %s: %w", stateName, err)
+		}
+		if !ok {
+			log.Debugf("workspace %s has no state", stateName)
+			continue
+		}
+		if err := addState(states, stateName, state); err != nil {
+			return err
+		}
+	}
+	if len(wanted) > 0 {
+		var missing []string
+		for name := range wanted {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("workspaces not found in organization %s: %s", t.Organization, strings.Join(missing, " "))
+	}
+	return nil
+}
+
+// workspaces the IDs of all workspaces in the organization, by name
+func (t TFCStateSource) workspaces(ctx context.Context) (map[string]string, error) {
+	workspaces := make(map[string]string)
+	page := 1
+	for {
+		var result tfcWorkspaces
+		path := fmt.Sprintf("/api/v2/organizations/%s/workspaces?page%%5Bnumber%%5D=%d&page%%5Bsize%%5D=%d", url.PathEscape(t.Organization), page, tfcPageSize)
+		found, err := t.getJSON(ctx, t.address()+path, &result)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("organization %s not found", t.Organization)
+		}
+		for _, workspace := range result.Data {
+			workspaces[workspace.Attributes.Name] = workspace.ID
+		}
+		if result.Meta.Pagination.NextPage == nil {
+			return workspaces, nil
+		}
+		page = *result.Meta.Pagination.NextPage
+	}
+}
+
+// currentState get the current state of the workspace; returns false if it has none
+func (t TFCStateSource) currentState(ctx context.Context, workspaceID string) (TerraformState, bool, error) {
+	var version tfcStateVersion
+	found, err := t.getJSON(ctx, fmt.Sprintf("%s/api/v2/workspaces/%s/current-state-version", t.address(), url.PathEscape(workspaceID)), &version)
+	if err != nil || !found {
+		return TerraformState{}, false, err
+	}
+	downloadURL := version.Data.Attributes.HostedStateDownloadURL
+	if downloadURL == "" {
+		return TerraformState{}, false, nil
+	}
+	body, found, err := t.get(ctx, downloadURL)
+	if err != nil {
+		return TerraformState{}, false, err
+	}
+	if !found {
+		return TerraformState{}, false, fmt.Errorf("state download %s not found", downloadURL)
+	}
+	defer body.Close()
+	state, err := DecodeTerraformState(body)
+	return state, true, err
+}
+
+// getJSON get and decode the API response into v; returns false if it does not exist
+func (t TFCStateSource) getJSON(ctx context.Context, u string, v interface{}) (bool, error) {
+	body, found, err := t.get(ctx, u)
+	if err != nil || !found {
+		return false, err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return false, fmt.Errorf("unable to decode response of %s: %w", u, err)
+	}
+	return true, nil
+}
+
+// get send an authenticated GET request; returns false if the response is 404 Not Found
+func (t TFCStateSource) get(ctx context.Context, u string) (io.ReadCloser, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.Token)
+	req.Header.Set("Content-Type", tfcContentType)
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, true, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, false, nil
+	default:
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, false, fmt.Errorf("GET %s: %s: %s", u, res.Status, strings.TrimSpace(string(msg)))
+	}
+}
+
+func (t TFCStateSource) address() string {
+	if t.Address == "" {
+		return DefaultTFCAddress
+	}
+	return strings.TrimSuffix(t.Address, "/")
+}
@@ -0,0 +1,103 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTFCServer a fake Terraform Cloud with the workspaces of the organization acme, in pages of
+// two: prod and staging, which have states, and new, which has none
+func newTFCServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("%s: Authorization = %q, want Bearer secret", r.URL, got)
+		}
+		switch r.URL.Path {
+		case "/api/v2/organizations/acme/workspaces":
+			if got := r.URL.Query().Get("page[size]"); got != "100" {
+				t.Errorf("page size = %s, want 100", got)
+			}
+			switch page := r.URL.Query().Get("page[number]"); page {
+			case "1":
+				fmt.Fprint(w, `{"data": [{"id": "ws-1", "attributes": {"name": "prod"}}, {"id": "ws-2", "attributes": {"name": "staging"}}],
+					"meta": {"pagination": {"current-page": 1, "next-page": 2}}}`)
+			case "2":
+				fmt.Fprint(w, `{"data": [{"id": "ws-3", "attributes": {"name": "new"}}],
+					"meta": {"pagination": {"current-page": 2, "next-page": null}}}`)
+			default:
+				t.Errorf("unexpected page %s", page)
+				http.NotFound(w, r)
+			}
+		case "/api/v2/workspaces/ws-1/current-state-version", "/api/v2/workspaces/ws-2/current-state-version":
+			id := strings.Split(r.URL.Path, "/")[4]
+			fmt.Fprintf(w, `{"data": {"attributes": {"hosted-state-download-url": "%s/download/%s"}}}`, server.URL, id)
+		case "/download/ws-1", "/download/ws-2":
+			fmt.Fprint(w, testState)
+		case "/api/v2/organizations/acme/broken":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		default:
+			// including the current state version of ws-3, which has none
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTFCStateSourceLoad(t *testing.T) {
+	server := newTFCServer(t)
+	tests := []struct {
+		name         string
+		organization string
+		workspaces   []string
+		want         []string
+		err          string
+	}{
+		{"all workspaces across pages", "acme", nil, []string{"tfc://acme/prod", "tfc://acme/staging"}, ""},
+		{"some workspaces", "acme", []string{"staging"}, []string{"tfc://acme/staging"}, ""},
+		{"workspace without state", "acme", []string{"new"}, nil, ""},
+		{"workspaces not found", "acme", []string{"prod", "qa", "dev"}, nil, "workspaces not found in organization acme: dev qa"},
+		{"organization not found", "other", nil, nil, "organization other not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := TFCStateSource{
+				Address:      server.URL + "/",
+				Token:        "secret",
+				Organization: tt.organization,
+				Workspaces:   tt.workspaces,
+				Client:       server.Client(),
+			}
+			states := make(map[string]TerraformState)
+			err := source.Load(context.Background(), states)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Load() = %v, want error %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stateNames(states); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("states = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTFCStateSourceGetError(t *testing.T) {
+	server := newTFCServer(t)
+	source := TFCStateSource{Address: server.URL, Token: "secret", Client: server.Client()}
+	_, _, err := source.get(context.Background(), server.URL+"/api/v2/organizations/acme/broken")
+	if err == nil || !strings.Contains(err.Error(), "500") || !strings.Contains(err.Error(), "internal error") {
+		t.Errorf("get() = %v, want the status and message", err)
+	}
+}
@@ -0,0 +1,67 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// decodeObject decode the json object that is next in dec one member at a time, rather than
+// all at once, so that only a single value is buffered. member is called with the key of each
+// member, and must decode its value from dec, e.g. with skipValue. A null is an empty object.
+func decodeObject(dec *json.Decoder, member func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected object, found %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected key of object, found %v", tok)
+		}
+		if err := member(key); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	// the closing }
+	_, err = dec.Token()
+	return err
+}
+
+// decodeArray decode the json array that is next in dec one element at a time. element is
+// called for each, and must decode it from dec. A null is an empty array.
+func decodeArray(dec *json.Decoder, element func() error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected array, found %v", tok)
+	}
+	for i := 0; dec.More(); i++ {
+		if err := element(); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	// the closing ]
+	_, err = dec.Token()
+	return err
+}
+
+// skipValue decode and discard the value that is next in dec
+func skipValue(dec *json.Decoder) error {
+	var discard json.RawMessage
+	return dec.Decode(&discard)
+}
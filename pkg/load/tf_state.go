@@ -2,29 +2,30 @@ package load
 
 const (
 	TerraformManaged = "managed"
+	TerraformData    = "data"
 )
 
 type TerraformState struct {
-	Version          int
-	TerraformVersion string
-	Serial           int
-	Lineage          string
-	Outputs          map[string]interface{}
-	Resources        []Resource
+	Version          int                    `json:"version"`
+	TerraformVersion string                 `json:"terraform_version"`
+	Serial           int                    `json:"serial"`
+	Lineage          string                 `json:"lineage"`
+	Outputs          map[string]interface{} `json:"outputs"`
+	Resources        []Resource             `json:"resources"`
 }
 
 type Resource struct {
-	Module    string
-	Mode      string
-	Type      string
-	Name      string
-	Provider  string
-	Instances []Instance
+	Module    string     `json:"module,omitempty"`
+	Mode      string     `json:"mode"`
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Provider  string     `json:"provider"`
+	Instances []Instance `json:"instances"`
 }
 
 type Instance struct {
-	SchemaVersion int
-	Attributes    map[string]interface{}
-	Private       string
-	IndexKey      string
+	SchemaVersion int                    `json:"schema_version"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	Private       string                 `json:"private,omitempty"`
+	IndexKey      interface{}            `json:"index_key,omitempty"` // string for for_each, number for count
 }
@@ -0,0 +1,354 @@
+package load
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// terraformStateV4 the first version of the state with the resources[].instances[] layout
+	terraformStateV4 = 4
+	// terraformRootModule the path of the root module in legacy states
+	terraformRootModule = "root"
+	// legacy flattened attributes, e.g. tags.% is the number of tags, and tags.Name a single tag
+	legacyMapCount  = "%"
+	legacyListCount = "#"
+)
+
+// rawTerraformState every top-level field of all of the supported formats, so that the format
+// can be detected while decoding the input only once.
+type rawTerraformState struct {
+	Version          int                    `json:"version"`
+	TerraformVersion string                 `json:"terraform_version"`
+	Serial           int                    `json:"serial"`
+	Lineage          string                 `json:"lineage"`
+	Outputs          map[string]interface{} `json:"outputs"`
+	// Resources version 4 and later
+	Resources []Resource `json:"resources"`
+	// Modules version 3 and earlier
+	Modules []legacyModule `json:"modules"`
+	// FormatVersion and Values terraform show -json
+	FormatVersion string      `json:"format_version"`
+	Values        *showValues `json:"values"`
+}
+
+// legacyModule a module in a version 3 or earlier state
+type legacyModule struct {
+	Path      []string                  `json:"path"`
+	Outputs   map[string]interface{}    `json:"outputs"`
+	Resources map[string]legacyResource `json:"resources"`
+}
+
+// legacyResource a single resource instance in a version 3 or earlier state, keyed
+// by [data.]<type>.<name>[.<index>]
+type legacyResource struct {
+	Type     string          `json:"type"`
+	Provider string          `json:"provider"`
+	Primary  *legacyInstance `json:"primary"`
+}
+
+type legacyInstance struct {
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// showValues the values of the output of terraform show -json
+type showValues struct {
+	Outputs    map[string]interface{} `json:"outputs"`
+	RootModule showModule             `json:"root_module"`
+}
+
+type showModule struct {
+	Address      string         `json:"address"`
+	Resources    []showResource `json:"resources"`
+	ChildModules []showModule   `json:"child_modules"`
+}
+
+type showResource struct {
+	Mode          string                 `json:"mode"`
+	Type          string                 `json:"type"`
+	Name          string                 `json:"name"`
+	Index         interface{}            `json:"index"`
+	ProviderName  string                 `json:"provider_name"`
+	SchemaVersion int                    `json:"schema_version"`
+	Values        map[string]interface{} `json:"values"`
+}
+
+// DecodeTerraformState decode a single terraform state, in any of the supported formats:
+// version 4 and later, version 3 and earlier, or the output of terraform show -json.
+// Every format is normalized to the version 4 layout of Resources and Instances.
+// The resources and modules of states are decoded one at a time, so that the input is not held
+// in memory all at once; the output of terraform show -json is decoded at once.
+func DecodeTerraformState(r io.Reader) (TerraformState, error) {
+	var (
+		raw rawTerraformState
+		dec = json.NewDecoder(r)
+	)
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "version":
+			return dec.Decode(&raw.Version)
+		case "terraform_version":
+			return dec.Decode(&raw.TerraformVersion)
+		case "serial":
+			return dec.Decode(&raw.Serial)
+		case "lineage":
+			return dec.Decode(&raw.Lineage)
+		case "outputs":
+			return dec.Decode(&raw.Outputs)
+		case "resources":
+			return decodeArray(dec, func() error {
+				var resource Resource
+				if err := dec.Decode(&resource); err != nil {
+					return err
+				}
+				raw.Resources = append(raw.Resources, resource)
+				return nil
+			})
+		case "modules":
+			// a modules key, even if empty, is a legacy state
+			raw.Modules = []legacyModule{}
+			return decodeArray(dec, func() error {
+				var module legacyModule
+				if err := dec.Decode(&module); err != nil {
+					return err
+				}
+				raw.Modules = append(raw.Modules, module)
+				return nil
+			})
+		case "format_version":
+			return dec.Decode(&raw.FormatVersion)
+		case "values":
+			return dec.Decode(&raw.Values)
+		default:
+			return skipValue(dec)
+		}
+	})
+	if err != nil {
+		return TerraformState{}, err
+	}
+	state := TerraformState{
+		Version:          raw.Version,
+		TerraformVersion: raw.TerraformVersion,
+		Serial:           raw.Serial,
+		Lineage:          raw.Lineage,
+		Outputs:          raw.Outputs,
+	}
+	switch {
+	case raw.FormatVersion != "":
+		if raw.Values == nil {
+			return state, errors.New("terraform show output has no values; it must be of a state, not a plan")
+		}
+		state.Outputs = raw.Values.Outputs
+		state.Resources = convertShowModule(raw.Values.RootModule, nil)
+	case raw.Version >= terraformStateV4:
+		state.Resources = raw.Resources
+	case raw.Version > 0 || raw.Modules != nil:
+		resources, err := convertLegacyModules(raw.Modules)
+		if err != nil {
+			return state, err
+		}
+		state.Resources = resources
+	default:
+		return state, errors.New("unknown terraform state format, neither a state nor the output of terraform show -json")
+	}
+	return state, nil
+}
+
+// convertShowModule convert the resources of a module of terraform show -json, and all of its
+// children, appending them to resources.
+func convertShowModule(module showModule, resources []Resource) []Resource {
+	byAddress := make(map[string]int)
+	for _, r := range module.Resources {
+		address := strings.Join([]string{r.Mode, r.Type, r.Name}, ".")
+		i, ok := byAddress[address]
+		if !ok {
+			i = len(resources)
+			byAddress[address] = i
+			resources = append(resources, Resource{
+				Module:   module.Address,
+				Mode:     r.Mode,
+				Type:     r.Type,
+				Name:     r.Name,
+				Provider: fmt.Sprintf(`provider[%q]`, r.ProviderName),
+			})
+		}
+		resources[i].Instances = append(resources[i].Instances, Instance{
+			SchemaVersion: r.SchemaVersion,
+			Attributes:    r.Values,
+			IndexKey:      r.Index,
+		})
+	}
+	for _, child := range module.ChildModules {
+		resources = convertShowModule(child, resources)
+	}
+	return resources
+}
+
+// convertLegacyModules convert the modules of a version 3 or earlier state. Resources are
+// sorted by address, as the legacy format keeps them in a map.
+func convertLegacyModules(modules []legacyModule) ([]Resource, error) {
+	var resources []Resource
+	for _, module := range modules {
+		var (
+			moduleAddress string
+			keys          []string
+			byAddress     = make(map[string]int)
+		)
+		for _, p := range module.Path {
+			if p != terraformRootModule {
+				moduleAddress = strings.TrimPrefix(moduleAddress+".module."+p, ".")
+			}
+		}
+		for key := range module.Resources {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			legacy := module.Resources[key]
+			if legacy.Primary == nil {
+				// only deposed instances remain, which are about to be destroyed
+				continue
+			}
+			mode, resourceType, name, index, err := parseLegacyKey(key)
+			if err != nil {
+				return nil, err
+			}
+			if legacy.Type != "" {
+				resourceType = legacy.Type
+			}
+			address := strings.Join([]string{mode, resourceType, name}, ".")
+			i, ok := byAddress[address]
+			if !ok {
+				i = len(resources)
+				byAddress[address] = i
+				resources = append(resources, Resource{
+					Module:   moduleAddress,
+					Mode:     mode,
+					Type:     resourceType,
+					Name:     name,
+					Provider: legacy.Provider,
+				})
+			}
+			attributes := unflattenLegacyAttributes(legacy.Primary.Attributes, "")
+			if _, ok := attributes["id"]; !ok && legacy.Primary.ID != "" {
+				attributes["id"] = legacy.Primary.ID
+			}
+			resources[i].Instances = append(resources[i].Instances, Instance{
+				Attributes: attributes,
+				IndexKey:   index,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// parseLegacyKey parse the key of a legacy resource, [data.]<type>.<name>[.<index>]
+func parseLegacyKey(key string) (mode, resourceType, name string, index interface{}, err error) {
+	mode = TerraformManaged
+	parts := strings.Split(key, ".")
+	if parts[0] == TerraformData {
+		mode = TerraformData
+		parts = parts[1:]
+	}
+	switch len(parts) {
+	case 2:
+	case 3:
+		i, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return "", "", "", nil, fmt.Errorf("invalid resource index in %s: %w", key, err)
+		}
+		// the same type as an index decoded from a version 4 state
+		index = float64(i)
+	default:
+		return "", "", "", nil, fmt.Errorf("invalid resource key %s", key)
+	}
+	return mode, parts[0], parts[1], index, nil
+}
+
+// unflattenLegacyAttributes convert the flattened attributes of a legacy state, under the
+// given prefix, to the nested maps and lists of a version 4 state. Legacy states only have
+// strings, so all values remain strings.
+func unflattenLegacyAttributes(flat map[string]string, prefix string) map[string]interface{} {
+	var (
+		attributes = make(map[string]interface{})
+		names      = make(map[string]bool)
+	)
+	for key := range flat {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(key, prefix), ".")
+		names[name] = true
+	}
+	for name := range names {
+		full := prefix + name
+		_, isMap := flat[full+"."+legacyMapCount]
+		_, isList := flat[full+"."+legacyListCount]
+		switch {
+		case isMap:
+			// map keys may themselves contain dots, e.g. kubernetes.io/cluster/name tags
+			m := make(map[string]interface{})
+			for key, value := range flat {
+				if rest, ok := strings.CutPrefix(key, full+"."); ok && rest != legacyMapCount {
+					m[rest] = value
+				}
+			}
+			attributes[name] = m
+		case isList:
+			attributes[name] = unflattenLegacyList(flat, full+".")
+		default:
+			if value, ok := flat[full]; ok {
+				attributes[name] = value
+			}
+		}
+	}
+	return attributes
+}
+
+// unflattenLegacyList convert a flattened list or set under the prefix, whose elements are
+// either strings, e.g. list.0, or objects, e.g. list.0.name. Set elements are keyed by hash
+// rather than index, so elements are ordered numerically by key.
+func unflattenLegacyList(flat map[string]string, prefix string) []interface{} {
+	var (
+		keys    []string
+		objects = make(map[string]bool)
+		seen    = make(map[string]bool)
+	)
+	for key := range flat {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || rest == legacyListCount {
+			continue
+		}
+		index, field, isObject := strings.Cut(rest, ".")
+		if isObject && field != "" {
+			objects[index] = true
+		}
+		if !seen[index] {
+			seen[index] = true
+			keys = append(keys, index)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(keys[i])
+		b, errB := strconv.Atoi(keys[j])
+		if errA != nil || errB != nil {
+			return keys[i] < keys[j]
+		}
+		return a < b
+	})
+	list := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if objects[key] {
+			list = append(list, unflattenLegacyAttributes(flat, prefix+key+"."))
+		} else {
+			list = append(list, flat[prefix+key])
+		}
+	}
+	return list
+}
@@ -0,0 +1,598 @@
+// Package sqlitestore a compare.Store in a SQLite database on disk, which can also keep the
+// reconciled inventory, so that later runs can use it without reading the inputs and reconciling
+// again. It uses a pure-Go SQLite, so needs no cgo.
+package sqlitestore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/iac-reconciler/aws-config/pkg/load"
+	// registers the sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+const driverName = "sqlite"
+
+const (
+	// eachBatch the number of items that Each reads from the database at a time
+	eachBatch = 1000
+	// DefaultCacheSize the number of items that a Store keeps in memory, by default, before
+	// Release writes them back and drops them
+	DefaultCacheSize = 10000
+)
+
+// schema the tables of the items, by handle, with the lean record of each and its complete
+// configuration item, if it has one apart from the record; of the index of a call to Reconcile;
+// of the inventory; and of metadata about the inventory, e.g. compare.Stats
+const schema = `
+CREATE TABLE IF NOT EXISTS items (
+	handle INTEGER PRIMARY KEY,
+	record BLOB    NOT NULL,
+	detail BLOB
+);
+CREATE TABLE IF NOT EXISTS keys (
+	kind          TEXT    NOT NULL,
+	resource_type TEXT    NOT NULL,
+	key           TEXT    NOT NULL,
+	account_id    TEXT    NOT NULL,
+	region        TEXT    NOT NULL,
+	item          INTEGER NOT NULL,
+	PRIMARY KEY (kind, resource_type, key, account_id, region)
+);
+CREATE TABLE IF NOT EXISTS inventory (
+	position INTEGER PRIMARY KEY,
+	item     INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS metadata (
+	key   TEXT PRIMARY KEY,
+	value BLOB NOT NULL
+);
+`
+
+// ErrNoInventory the database has no inventory, because none was saved
+var ErrNoInventory = errors.New("no inventory in the store")
+
+// Option an option of a Store.
+type Option func(*Store)
+
+// WithCacheSize keep up to n items in memory before Release writes them back and drops them,
+// rather than DefaultCacheSize.
+func WithCacheSize(n int) Option {
+	return func(s *Store) {
+		s.cacheSize = n
+	}
+}
+
+// Store a compare.Store in a SQLite database. Every item is kept in the database, by a handle: its
+// lean record, which is rewritten as it changes, and its complete configuration item, as given to
+// SetDetail. Only the items returned since Release last dropped them are in memory, so that
+// Reconcile can change them; Release writes back their changes once there are more than the cache
+// size. Changes are in a single transaction, which is committed by Commit and Close.
+type Store struct {
+	db        *sql.DB
+	tx        *sql.Tx
+	stmts     statements
+	cacheSize int
+	// cache the items in memory, by handle, and the handle of each
+	cache   map[int64]*cachedItem
+	handles map[*compare.LocatedItem]int64
+	// detailHandle and detail the last complete configuration item that was read, as rules and
+	// lookups read the same one several times in a row
+	detailHandle int64
+	detail       *load.ConfigurationItem
+	// err the first error of resolving a parent, which cannot return one
+	err error
+}
+
+// cachedItem an item in memory, with the hash of its record as it is in the database, so that
+// it is only written back if it changed
+type cachedItem struct {
+	item *compare.LocatedItem
+	sum  uint64
+}
+
+// statements the prepared statements of the transaction
+type statements struct {
+	// insertKey and replaceKey put a key, keeping or replacing an existing item in the same scope;
+	// both keep the order of the first put, which is the order of the candidates
+	insertKey, replaceKey, candidates                     *sql.Stmt
+	insertItem, updateRecord, selectRecord                *sql.Stmt
+	updateDetail, selectDetail, insertInventory, eachKeys *sql.Stmt
+}
+
+// Create create a store in a new database at the path, replacing any existing file, for
+// compare.WithStore.
+func Create(path string, opts ...Option) (*Store, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to replace store %s: %w", path, err)
+	}
+	return open(path, opts)
+}
+
+// Open open the store in an existing database at the path, e.g. to LoadInventory.
+func Open(path string, opts ...Option) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("unable to open store %s: %w", path, err)
+	}
+	return open(path, opts)
+}
+
+func open(path string, opts []Option) (*Store, error) {
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open store %s: %w", path, err)
+	}
+	// a single connection, which the transaction holds throughout
+	db.SetMaxOpenConns(1)
+	s := &Store{
+		db:        db,
+		cacheSize: DefaultCacheSize,
+		cache:     make(map[int64]*cachedItem),
+		handles:   make(map[*compare.LocatedItem]int64),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to initialize store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *Store) init() error {
+	// the database is a cache of the inputs, which can always be created again, so it need not
+	// survive a crash
+	for _, pragma := range []string{"PRAGMA journal_mode = OFF", "PRAGMA synchronous = OFF"} {
+		if _, err := s.db.Exec(pragma); err != nil {
+			return err
+		}
+	}
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+	return s.begin()
+}
+
+// begin begin a transaction, and prepare the statements in it
+func (s *Store) begin() error {
+	var err error
+	if s.tx, err = s.db.Begin(); err != nil {
+		return err
+	}
+	for _, stmt := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.stmts.insertKey, `INSERT INTO keys (kind, resource_type, key, account_id, region, item)
+			VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`},
+		{&s.stmts.replaceKey, `INSERT INTO keys (kind, resource_type, key, account_id, region, item)
+			VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO UPDATE SET item = excluded.item`},
+		{&s.stmts.candidates, `SELECT item FROM keys WHERE kind = ? AND resource_type = ? AND key = ? ORDER BY rowid`},
+		// by rowid, not by the index of the primary key, which would sort every key of the kind
+		{&s.stmts.eachKeys, `SELECT keys.rowid, keys.item, items.record FROM keys JOIN items ON items.handle = keys.item
+			WHERE +keys.kind = ? AND keys.rowid > ? ORDER BY keys.rowid LIMIT ?`},
+		{&s.stmts.insertItem, `INSERT INTO items (record, detail) VALUES (?, ?)`},
+		{&s.stmts.updateRecord, `UPDATE items SET record = ? WHERE handle = ?`},
+		{&s.stmts.selectRecord, `SELECT record FROM items WHERE handle = ?`},
+		{&s.stmts.updateDetail, `UPDATE items SET detail = ? WHERE handle = ?`},
+		{&s.stmts.selectDetail, `SELECT detail FROM items WHERE handle = ?`},
+		{&s.stmts.insertInventory, `INSERT INTO inventory (position, item) VALUES (?, ?)`},
+	} {
+		if *stmt.stmt, err = s.tx.Prepare(stmt.query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle the handle of the item, which is stored first if it is not yet, e.g. as it was just created
+func (s *Store) handle(item *compare.LocatedItem) (int64, error) {
+	if handle, ok := s.handles[item]; ok {
+		return handle, nil
+	}
+	return s.insert(item, nil)
+}
+
+// insert store the item, which is not yet, with the encoded detail, if any
+func (s *Store) insert(item *compare.LocatedItem, detail []byte) (int64, error) {
+	// the record is written when the item is written back, as it is still being changed
+	result, err := s.stmts.insertItem.Exec([]byte("{}"), detail)
+	if err != nil {
+		return 0, fmt.Errorf("unable to store %s %s: %w", item.ResourceType, item.ResourceID, err)
+	}
+	handle, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("unable to store %s %s: %w", item.ResourceType, item.ResourceID, err)
+	}
+	s.cache[handle] = &cachedItem{item: item}
+	s.handles[item] = handle
+	return handle, nil
+}
+
+// item the item of the handle, which is read from the database if it is not in memory
+func (s *Store) item(handle int64) (*compare.LocatedItem, error) {
+	if item, ok := s.cached(handle); ok {
+		return item, nil
+	}
+	var b []byte
+	if err := s.stmts.selectRecord.QueryRow(handle).Scan(&b); err != nil {
+		return nil, fmt.Errorf("unable to read item %d: %w", handle, err)
+	}
+	return s.decode(handle, b)
+}
+
+// decode the item of the handle from its record, as read from the database
+func (s *Store) decode(handle int64, b []byte) (*compare.LocatedItem, error) {
+	var record compare.InventoryItem
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("unable to decode item %d: %w", handle, err)
+	}
+	item := compare.FromInventoryItem(record, s, s.parent)
+	s.cache[handle] = &cachedItem{item: item, sum: sum(b)}
+	s.handles[item] = handle
+	return item, nil
+}
+
+// cached the item of the handle, if it is in memory
+func (s *Store) cached(handle int64) (*compare.LocatedItem, bool) {
+	if cached, ok := s.cache[handle]; ok {
+		return cached.item, true
+	}
+	return nil, false
+}
+
+// parent the item of the handle, as the parent of another; an error is kept, to be returned
+// by Release, Commit or Close
+func (s *Store) parent(handle int64) *compare.LocatedItem {
+	item, err := s.item(handle)
+	if err != nil {
+		s.fail(err)
+		return nil
+	}
+	return item
+}
+
+func (s *Store) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Put implements compare.Store
+func (s *Store) Put(by compare.IndexKey, key string, item *compare.LocatedItem, replace bool) error {
+	handle, err := s.handle(item)
+	if err != nil {
+		return err
+	}
+	stmt := s.stmts.insertKey
+	if replace {
+		stmt = s.stmts.replaceKey
+	}
+	if _, err := stmt.Exec(string(by), item.ResourceType, key, item.AccountID, item.Region, handle); err != nil {
+		return fmt.Errorf("unable to store %s %s by %s: %w", item.ResourceType, key, by, err)
+	}
+	return nil
+}
+
+// Candidates implements compare.Store
+func (s *Store) Candidates(by compare.IndexKey, resourceType, key string) ([]*compare.LocatedItem, error) {
+	handles, err := scanHandles(s.stmts.candidates.Query(string(by), resourceType, key))
+	if err != nil {
+		return nil, fmt.Errorf("unable to find %s %s by %s: %w", resourceType, key, by, err)
+	}
+	var items []*compare.LocatedItem
+	for _, handle := range handles {
+		item, err := s.item(handle)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// scanHandles the handles in the only column of the rows, which are closed
+func scanHandles(rows *sql.Rows, err error) ([]int64, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var handles []int64
+	for rows.Next() {
+		var handle int64
+		if err := rows.Scan(&handle); err != nil {
+			return nil, err
+		}
+		handles = append(handles, handle)
+	}
+	return handles, rows.Err()
+}
+
+// Each implements compare.Store
+func (s *Store) Each(fn func(item *compare.LocatedItem) error) error {
+	// in batches, each queried after fn has been called with the previous one, as it may store more;
+	// the records are read with the handles, and only decoded for items that are not in memory
+	type row struct {
+		handle int64
+		record []byte
+	}
+	var last int64
+	for {
+		rows, err := s.stmts.eachKeys.Query(string(compare.ByID), last, eachBatch)
+		if err != nil {
+			return fmt.Errorf("unable to list items: %w", err)
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&last, &r.handle, &r.record); err != nil {
+				rows.Close()
+				return fmt.Errorf("unable to list items: %w", err)
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("unable to list items: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, r := range batch {
+			item, ok := s.cached(r.handle)
+			if !ok {
+				if item, err = s.decode(r.handle, r.record); err != nil {
+					return err
+				}
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SetDetail implements compare.Store. The detail is written to the database, and the item is
+// left with its lean record.
+func (s *Store) SetDetail(item *compare.LocatedItem, detail *load.ConfigurationItem) error {
+	if handle, ok := s.handles[item]; ok {
+		if err := s.writeDetail(handle, detail); err != nil {
+			return err
+		}
+	} else {
+		// not stored yet, e.g. as it was just created, so stored with it
+		b, err := encodeDetail(detail)
+		if err != nil {
+			return err
+		}
+		if _, err := s.insert(item, b); err != nil {
+			return err
+		}
+	}
+	item.ConfigurationItem = detail.Lean()
+	return nil
+}
+
+// encodeDetail encode the complete configuration item
+func encodeDetail(detail *load.ConfigurationItem) ([]byte, error) {
+	b, err := json.Marshal(compare.ToInventoryDetail(detail))
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode %s %s: %w", detail.ResourceType, detail.ResourceID, err)
+	}
+	return b, nil
+}
+
+// writeDetail write the complete configuration item of the handle
+func (s *Store) writeDetail(handle int64, detail *load.ConfigurationItem) error {
+	b, err := encodeDetail(detail)
+	if err != nil {
+		return err
+	}
+	if _, err := s.stmts.updateDetail.Exec(b, handle); err != nil {
+		return fmt.Errorf("unable to store %s %s: %w", detail.ResourceType, detail.ResourceID, err)
+	}
+	if s.detailHandle == handle {
+		s.detail = nil
+	}
+	return nil
+}
+
+// Detail implements compare.Store
+func (s *Store) Detail(item *compare.LocatedItem) (*load.ConfigurationItem, error) {
+	handle, ok := s.handles[item]
+	if !ok {
+		// not stored, so it has nothing apart from its own
+		return item.ConfigurationItem, nil
+	}
+	if s.detail != nil && s.detailHandle == handle {
+		return s.detail, nil
+	}
+	var b []byte
+	if err := s.stmts.selectDetail.QueryRow(handle).Scan(&b); err != nil {
+		return nil, fmt.Errorf("unable to read %s %s: %w", item.ResourceType, item.ResourceID, err)
+	}
+	if b == nil {
+		return item.ConfigurationItem, nil
+	}
+	var stored compare.InventoryDetail
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return nil, fmt.Errorf("unable to decode %s %s: %w", item.ResourceType, item.ResourceID, err)
+	}
+	detail, err := compare.FromInventoryDetail(stored)
+	if err != nil {
+		return nil, err
+	}
+	// the source file is only in the record
+	detail.SourceFile = item.SourceFile
+	s.detailHandle, s.detail = handle, detail
+	return detail, nil
+}
+
+// Release implements compare.Store. Items are only dropped once there are more than the cache
+// size, so that those used again soon after, e.g. a VPC, need not be read again.
+func (s *Store) Release() error {
+	if s.err != nil {
+		return s.err
+	}
+	if len(s.cache) < s.cacheSize {
+		return nil
+	}
+	if err := s.writeBack(); err != nil {
+		return err
+	}
+	s.cache = make(map[int64]*cachedItem)
+	s.handles = make(map[*compare.LocatedItem]int64)
+	return nil
+}
+
+// writeBack write the record of every item in memory that changed
+func (s *Store) writeBack() error {
+	// writing an item may store its parent, if it was not yet, so until none are left
+	written := make(map[int64]bool, len(s.cache))
+	for len(written) < len(s.cache) {
+		for handle, cached := range s.cache {
+			if written[handle] {
+				continue
+			}
+			written[handle] = true
+			record, err := compare.ToInventoryItem(cached.item, s.handle)
+			if err != nil {
+				return err
+			}
+			b, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("unable to encode item %d: %w", handle, err)
+			}
+			if sum := sum(b); sum != cached.sum {
+				if _, err := s.stmts.updateRecord.Exec(b, handle); err != nil {
+					return fmt.Errorf("unable to write item %d: %w", handle, err)
+				}
+				cached.sum = sum
+			}
+		}
+	}
+	return s.err
+}
+
+// sum the hash of a record
+func sum(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// SaveInventory save the items, e.g. those returned by compare.Reconcile, replacing any that were
+// saved before. Items that are not yet in the store, e.g. those reconciled in memory, are stored
+// with their complete configuration item.
+func (s *Store) SaveInventory(items []*compare.LocatedItem) error {
+	if _, err := s.tx.Exec(`DELETE FROM inventory`); err != nil {
+		return fmt.Errorf("unable to clear inventory: %w", err)
+	}
+	for position, item := range items {
+		handle, ok := s.handles[item]
+		if !ok {
+			detail, err := item.Detail()
+			if err != nil {
+				return err
+			}
+			b, err := encodeDetail(detail)
+			if err != nil {
+				return err
+			}
+			if handle, err = s.insert(item, b); err != nil {
+				return err
+			}
+		}
+		if _, err := s.stmts.insertInventory.Exec(position, handle); err != nil {
+			return fmt.Errorf("unable to save inventory item %d: %w", position, err)
+		}
+	}
+	return s.writeBack()
+}
+
+// LoadInventory load the items saved by SaveInventory, whose complete configuration items are
+// read from the store as they are needed, so it must not be closed while they are used.
+// Returns ErrNoInventory if there are none.
+func (s *Store) LoadInventory() ([]*compare.LocatedItem, error) {
+	handles, err := scanHandles(s.tx.Query(`SELECT item FROM inventory ORDER BY position`))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load inventory: %w", err)
+	}
+	if len(handles) == 0 {
+		return nil, ErrNoInventory
+	}
+	items := make([]*compare.LocatedItem, 0, len(handles))
+	for _, handle := range handles {
+		item, err := s.item(handle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load inventory: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// SetMetadata save the value under the key, as json, e.g. the compare.Stats of the inventory.
+func (s *Store) SetMetadata(key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("unable to encode metadata %s: %w", key, err)
+	}
+	if _, err := s.tx.Exec(`INSERT INTO metadata (key, value) VALUES (?, ?)
+		ON CONFLICT DO UPDATE SET value = excluded.value`, key, b); err != nil {
+		return fmt.Errorf("unable to save metadata %s: %w", key, err)
+	}
+	return nil
+}
+
+// Metadata decode the value saved under the key into value. Returns false if there is none.
+func (s *Store) Metadata(key string, value interface{}) (bool, error) {
+	var b []byte
+	err := s.tx.QueryRow(`SELECT value FROM metadata WHERE key = ?`, key).Scan(&b)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("unable to load metadata %s: %w", key, err)
+	}
+	if err := json.Unmarshal(b, value); err != nil {
+		return false, fmt.Errorf("unable to decode metadata %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Commit write back and commit every change so far, so that it is kept even if Close is not
+// called, e.g. as the process exits on an error. The store can still be used after.
+func (s *Store) Commit() error {
+	if err := s.writeBack(); err != nil {
+		return err
+	}
+	if err := s.tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit store: %w", err)
+	}
+	if err := s.begin(); err != nil {
+		return fmt.Errorf("unable to continue store: %w", err)
+	}
+	return nil
+}
+
+// Close write back and commit every change, and close the database.
+func (s *Store) Close() error {
+	err := s.writeBack()
+	if err == nil {
+		err = s.tx.Commit()
+	} else {
+		s.tx.Rollback()
+	}
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,269 @@
+package sqlitestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/iac-reconciler/aws-config/pkg/compare"
+	"github.com/iac-reconciler/aws-config/pkg/load"
+)
+
+const instanceType = "AWS::EC2::Instance"
+
+func newItem(id, region string) *compare.LocatedItem {
+	return &compare.LocatedItem{ConfigurationItem: &load.ConfigurationItem{
+		ResourceType: instanceType,
+		ResourceID:   id,
+		AccountID:    "123456789012",
+		Region:       region,
+		Tags:         map[string]string{"Name": id + " in " + region},
+	}}
+}
+
+// describe the items by what identifies them, as items read again are other *LocatedItem
+func describe(items []*compare.LocatedItem) []string {
+	var described []string
+	for _, item := range items {
+		described = append(described, fmt.Sprintf("%s %s %s %s", item.ResourceID, item.Region, item.Tags["Name"], item.Sources()))
+	}
+	return described
+}
+
+func TestStorePutCandidates(t *testing.T) {
+	// a cache of one item, so that every Release writes back and drops them
+	store, err := Create(filepath.Join(t.TempDir(), "store.db"), WithCacheSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	first, other, replacement := newItem("i-1", "us-east-1"), newItem("i-1", "us-west-2"), newItem("i-1", "us-east-1")
+	replacement.Tags["Name"] = "replacement"
+	tests := []struct {
+		name    string
+		item    *compare.LocatedItem
+		replace bool
+		want    []string
+	}{
+		{"insert", first, false, []string{"i-1 us-east-1 i-1 in us-east-1 []"}},
+		{"other scope", other, false, []string{"i-1 us-east-1 i-1 in us-east-1 []", "i-1 us-west-2 i-1 in us-west-2 []"}},
+		{"insert keeps", replacement, false, []string{"i-1 us-east-1 i-1 in us-east-1 []", "i-1 us-west-2 i-1 in us-west-2 []"}},
+		{"replace keeps order", replacement, true, []string{"i-1 us-east-1 replacement []", "i-1 us-west-2 i-1 in us-west-2 []"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Put(compare.ByID, "i-1", tt.item, tt.replace); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Release(); err != nil {
+				t.Fatal(err)
+			}
+			candidates, err := store.Candidates(compare.ByID, instanceType, "i-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(candidates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("write back", func(t *testing.T) {
+		candidates, err := store.Candidates(compare.ByID, instanceType, "i-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates[1].Tags["Name"] = "changed"
+		if err := store.Release(); err != nil {
+			t.Fatal(err)
+		}
+		if candidates, err = store.Candidates(compare.ByID, instanceType, "i-1"); err != nil {
+			t.Fatal(err)
+		}
+		if got := candidates[1].Tags["Name"]; got != "changed" {
+			t.Errorf("tag Name = %s, want changed", got)
+		}
+	})
+
+	t.Run("each", func(t *testing.T) {
+		if err := store.Put(compare.ByID, "i-2", newItem("i-2", "us-east-1"), false); err != nil {
+			t.Fatal(err)
+		}
+		var items []*compare.LocatedItem
+		err := store.Each(func(item *compare.LocatedItem) error {
+			items = append(items, item)
+			// stored while iterating, so also listed
+			if item.ResourceID == "i-2" {
+				return store.Put(compare.ByID, "i-3", newItem("i-3", "us-east-1"), false)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"i-1 us-east-1 replacement []",
+			"i-1 us-west-2 changed []",
+			"i-2 us-east-1 i-2 in us-east-1 []",
+			"i-3 us-east-1 i-3 in us-east-1 []",
+		}
+		if got := describe(items); !reflect.DeepEqual(got, want) {
+			t.Errorf("Each() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestStoreDetail(t *testing.T) {
+	store, err := Create(filepath.Join(t.TempDir(), "store.db"), WithCacheSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var detail load.ConfigurationItem
+	if err := json.Unmarshal([]byte(`{
+		"resourceType": "AWS::EC2::Instance",
+		"resourceId": "i-1",
+		"awsRegion": "us-east-1",
+		"configuration": {"instanceType": "t3.micro", "monitoring": {"state": "disabled"}},
+		"supplementaryConfiguration": {"unsupportedResources": [{"resourceType": "AWS::EC2::Volume", "resourceId": "vol-1"}]}
+	}`), &detail); err != nil {
+		t.Fatal(err)
+	}
+	detail.SourceFile = "snapshot.json"
+	item := &compare.LocatedItem{ConfigurationItem: &detail}
+	if err := store.SetDetail(item, &detail); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(compare.ByID, "i-1", item, true); err != nil {
+		t.Fatal(err)
+	}
+	if item.Configuration.Raw != nil || item.Configuration.InstanceType != "" {
+		t.Errorf("item keeps its configuration %s", item.Configuration.Raw)
+	}
+	if err := store.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := store.Candidates(compare.ByID, instanceType, "i-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Detail(candidates[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.Configuration.Raw) != string(detail.Configuration.Raw) {
+		t.Errorf("configuration = %s, want %s", got.Configuration.Raw, detail.Configuration.Raw)
+	}
+	if got.Configuration.InstanceType != "t3.micro" {
+		t.Errorf("instance type = %s, want t3.micro", got.Configuration.InstanceType)
+	}
+	if !reflect.DeepEqual(got.SupplementaryConfiguration.UnsupportedResources, detail.SupplementaryConfiguration.UnsupportedResources) {
+		t.Errorf("unsupported resources = %v, want %v", got.SupplementaryConfiguration.UnsupportedResources, detail.SupplementaryConfiguration.UnsupportedResources)
+	}
+	if got.SourceFile != "snapshot.json" {
+		t.Errorf("source file = %s, want snapshot.json", got.SourceFile)
+	}
+}
+
+func TestStoreInventory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	store, err := Create(path, WithCacheSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadInventory(); !errors.Is(err, ErrNoInventory) {
+		t.Errorf("LoadInventory() of an empty store = %v, want %v", err, ErrNoInventory)
+	}
+
+	var snapshot load.Snapshot
+	if err := json.Unmarshal([]byte(`{"configurationItems": [
+		{
+			"resourceType": "AWS::CloudFormation::Stack",
+			"resourceId": "arn:aws:cloudformation:us-east-1:123456789012:stack/app/1",
+			"resourceName": "app",
+			"awsRegion": "us-east-1",
+			"awsAccountId": "123456789012",
+			"configurationItemStatus": "OK",
+			"relationships": [{"resourceType": "AWS::EC2::Instance", "resourceId": "i-1", "name": "Contains"}]
+		},
+		{
+			"resourceType": "AWS::EC2::Instance",
+			"resourceId": "i-1",
+			"awsRegion": "us-east-1",
+			"awsAccountId": "123456789012",
+			"configurationItemStatus": "OK",
+			"configuration": {"instanceType": "t3.micro", "monitoring": {"state": "disabled"}}
+		}
+	]}`), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	items, err := compare.Reconcile(snapshot, nil, compare.WithStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveInventory(items); err != nil {
+		t.Fatal(err)
+	}
+	stats := compare.Stats{DeletedResources: 2}
+	if err := store.SetMetadata("stats", stats); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if store, err = Open(path, WithCacheSize(1)); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	loaded, err := store.LoadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(loaded), describe(items); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadInventory() = %v, want %v", got, want)
+	}
+	instance := loaded[1]
+	if parent := instance.Parent(); parent == nil || parent.ResourceType != "AWS::CloudFormation::Stack" {
+		t.Errorf("parent = %v, want the stack", parent)
+	}
+	if got := instance.OwnershipReason(); got != compare.OwnershipReasonStackContains {
+		t.Errorf("ownership reason = %s, want %s", got, compare.OwnershipReasonStackContains)
+	}
+	if !instance.Source(compare.SourceConfig) {
+		t.Errorf("sources = %v, want %s", instance.Sources(), compare.SourceConfig)
+	}
+	detail, err := instance.Detail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"instanceType":"t3.micro","monitoring":{"state":"disabled"}}`; string(detail.Configuration.Raw) != want {
+		t.Errorf("configuration = %s, want %s", detail.Configuration.Raw, want)
+	}
+
+	// the keys are kept by Open
+	candidates, err := store.Candidates(compare.ByID, instanceType, "i-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 {
+		t.Errorf("Candidates() = %v, want the instance", describe(candidates))
+	}
+
+	var gotStats compare.Stats
+	if ok, err := store.Metadata("stats", &gotStats); err != nil || !ok {
+		t.Fatalf("Metadata(stats) = %t, %v", ok, err)
+	}
+	if gotStats.DeletedResources != stats.DeletedResources {
+		t.Errorf("stats = %+v, want %+v", gotStats, stats)
+	}
+	if ok, err := store.Metadata("missing", &gotStats); err != nil || ok {
+		t.Errorf("Metadata(missing) = %t, %v, want false", ok, err)
+	}
+}